@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jkcfg/jk/pkg/resolve"
+	"github.com/jkcfg/jk/pkg/std"
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+var bundleCmd = &cobra.Command{
+	Use:     "bundle <script>",
+	Example: bundleExamples,
+	Short:   "Bundle a script and the modules it imports into a single script",
+	Args:    cobra.ExactArgs(1),
+	Run:     bundle,
+}
+
+const bundleExamples = `
+  bundling a script, including modules from a library image
+    jk bundle --lib jkcfg/kubernetes:0.6.0 ./deployment.js -o deployment.bundle.js
+
+  running the bundled script
+    jk run deployment.bundle.js
+`
+
+var bundleOptions struct {
+	vmOptions
+	outputFile string
+}
+
+func init() {
+	bundleOptions.parameters = std.NewParams()
+	initModuleFlags(bundleCmd, &bundleOptions.vmOptions)
+	bundleCmd.PersistentFlags().StringVarP(&bundleOptions.outputFile, "output", "o", "", "file to write the bundled script to; if not given, it is written to stdout")
+	jk.AddCommand(bundleCmd)
+}
+
+func bundle(cmd *cobra.Command, args []string) {
+	filename := args[0]
+	scriptDir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		log.Fatal(err)
+	}
+	applyProjectConfig(cmd, &bundleOptions.vmOptions, scriptDir)
+	vm := newVM(&bundleOptions.vmOptions, scriptDir)
+
+	input, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Modules are found by a resolver set up just as for `jk run`,
+	// but collected by the bundler rather than loaded.
+	bundler := resolve.NewBundler()
+	resolver := vm.newResolver(bundler)
+	script := vfs.Location{Vfs: vfs.User(vm.scriptDir, http.Dir(vm.scriptDir)), Path: filepath.Base(filename)}
+	source, err := resolver.Translate(script, input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out, err := bundler.Bundle(filepath.Base(filename), string(source), resolver.ResolveModule)
+	if err != nil {
+		log.Fatalf("bundle: %v", err)
+	}
+
+	if bundleOptions.outputFile == "" {
+		fmt.Print(string(out))
+		return
+	}
+	if err := ioutil.WriteFile(bundleOptions.outputFile, out, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+
+	"github.com/jkcfg/jk/pkg/cli"
+	"github.com/jkcfg/jk/pkg/config"
+	"github.com/jkcfg/jk/pkg/std"
+)
+
+// applyProjectConfig looks for a project configuration file (jk.yaml)
+// in dir or one of its parents, and uses it to supply values for the
+// options not given on the command line. Parameter files from the
+// configuration are loaded _before_ any parameters from the
+// environment (with --params-from-env) or the command line, so that
+// those take precedence.
+func applyProjectConfig(cmd *cobra.Command, opts *vmOptions, dir string) {
+	applyParamsFromEnv(opts)
+
+	path, err := config.Find(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if path == "" {
+		return
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.configFile = cfg.Path
+
+	flags := cmd.Flags()
+	unset := func(flag string) bool {
+		return flags.Lookup(flag) != nil && !flags.Changed(flag)
+	}
+
+	if unset("lib") {
+		var libs []name.Reference
+		for _, lib := range cfg.Libraries {
+			ref, err := cli.ParseImageRef(lib)
+			if err != nil {
+				log.Fatalf("%s: %s: %v", cfg.Path, lib, err)
+			}
+			libs = append(libs, ref)
+		}
+		opts.libraryImages = libs
+	}
+	if unset("cache") && cfg.Cache != "" {
+		opts.cacheDir = cfg.Cache
+	}
+	if unset("input-directory") && cfg.InputDirectory != "" {
+		opts.inputDirectory = cfg.InputDirectory
+	}
+	if unset("output-directory") && cfg.OutputDirectory != "" {
+		opts.outputDirectory = cfg.OutputDirectory
+	}
+	if unset("import-map") && cfg.ImportMap != "" {
+		opts.importMap = cfg.ImportMap
+	}
+	if unset("allow-exec") {
+		opts.allowExec = cfg.AllowExec
+	}
+	if unset("env-allow") {
+		opts.envAllow = cfg.EnvAllow
+	}
+	if unset("strict-params") && cfg.StrictParams {
+		opts.strictParams = true
+	}
+
+	if len(cfg.Parameters) > 0 {
+		params := std.NewParams()
+		for _, f := range cfg.Parameters {
+			p, err := std.NewParamsFromFile(f)
+			if err != nil {
+				log.Fatalf("%s: %v", f, err)
+			}
+			params.Merge(p)
+		}
+		params.Merge(opts.parameters)
+		opts.parameters = params
+		opts.parameterFiles = append(cfg.Parameters, opts.parameterFiles...)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jkcfg/jk/pkg/resolve"
+	"github.com/jkcfg/jk/pkg/std"
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+var depsCmd = &cobra.Command{
+	Use:     "deps <script>",
+	Example: depsExamples,
+	Short:   "Print the import graph of a script",
+	Args:    cobra.ExactArgs(1),
+	Run:     deps,
+}
+
+const depsExamples = `
+  printing the modules imported by a script, as a tree
+    jk deps ./deployment.js
+
+  including the standard library modules, and rendering with Graphviz
+    jk deps --std --format dot ./deployment.js | dot -Tsvg > imports.svg
+`
+
+var depsOptions struct {
+	vmOptions
+	format     string
+	includeStd bool
+}
+
+func init() {
+	depsOptions.parameters = std.NewParams()
+	initModuleFlags(depsCmd, &depsOptions.vmOptions)
+	depsCmd.PersistentFlags().StringVar(&depsOptions.format, "format", "tree", "output format: tree, json or dot")
+	depsCmd.PersistentFlags().BoolVar(&depsOptions.includeStd, "std", false, "include standard library and other built-in modules")
+	jk.AddCommand(depsCmd)
+}
+
+func deps(cmd *cobra.Command, args []string) {
+	switch depsOptions.format {
+	case "tree", "json", "dot":
+	default:
+		log.Fatalf("deps: unknown format %q (expected tree, json or dot)", depsOptions.format)
+	}
+
+	filename := args[0]
+	scriptDir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		log.Fatal(err)
+	}
+	applyProjectConfig(cmd, &depsOptions.vmOptions, scriptDir)
+	vm := newVM(&depsOptions.vmOptions, scriptDir)
+
+	input, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Walk the module graph with a resolver set up as for `jk run`,
+	// collecting the imports as they are resolved.
+	graph := resolve.NewGraph()
+	walker := resolve.NewBundler()
+	resolver := vm.newResolver(walker)
+	resolver.SetGraph(graph)
+	root := filepath.Base(filename)
+	script := vfs.Location{Vfs: vfs.User(vm.scriptDir, http.Dir(vm.scriptDir)), Path: root}
+	source, err := resolver.Translate(script, input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := walker.Walk(root, string(source), resolver.ResolveModule); err != nil {
+		log.Fatalf("deps: %v", err)
+	}
+
+	if !depsOptions.includeStd {
+		graph = graph.Filter(func(i resolve.Import) bool {
+			return !i.Internal && !resolve.IsStdModule(i.Referrer)
+		})
+	}
+
+	switch depsOptions.format {
+	case "tree":
+		graph.WriteTree(os.Stdout, root)
+	case "dot":
+		graph.WriteDOT(os.Stdout)
+	case "json":
+		imports := graph.Imports()
+		if imports == nil {
+			imports = []resolve.Import{}
+		}
+		out := struct {
+			Module  string           `json:"module"`
+			Imports []resolve.Import `json:"imports"`
+		}{root, imports}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(data))
+	}
+}
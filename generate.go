@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/jkcfg/jk/pkg/runcache"
 	"github.com/jkcfg/jk/pkg/std"
 )
 
@@ -30,6 +32,8 @@ func generateExamples() string {
 	b.WriteString("    jk generate -v -p path.k1.k2=value ./scriptdir/script.js\n")
 	b.WriteString("  specifying input parameters and file containing parameters\n")
 	b.WriteString("    jk generate -v -p key=value -f filename.json script.js\n")
+	b.WriteString("  skipping the script if nothing it uses has changed since the last run\n")
+	b.WriteString("    jk generate --incremental -o ./outputdir script.js\n")
 	return b.String()
 }
 
@@ -43,6 +47,7 @@ func init() {
 	initAllVMFlags(generateCmd, &generateOptions.vmOptions)
 
 	generateCmd.PersistentFlags().BoolVar(&generateOptions.stdout, "stdout", false, "print values on stdout")
+	generateCmd.PersistentFlags().BoolVar(&generateOptions.incremental, "incremental", false, "restore the files written from the run cache, rather than running the script, if nothing it uses has changed")
 
 	jk.AddCommand(generateCmd)
 }
@@ -59,22 +64,96 @@ func generateArgs(cmd *cobra.Command, args []string) error {
 }
 
 func generate(cmd *cobra.Command, args []string) {
+	filename := args[0]
+	scriptDir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		log.Fatal(err)
+	}
+	applyProjectConfig(cmd, &generateOptions.vmOptions, scriptDir)
+
 	if generateOptions.inputDirectory == "" {
-		filename := args[0]
-		inputDir, err := filepath.Abs(filepath.Dir(filename))
-		if err != nil {
-			log.Fatal(err)
-		}
-		generateOptions.inputDirectory = inputDir
+		generateOptions.inputDirectory = scriptDir
+	}
+
+	// Nothing is written to files when emitting dependencies or
+	// printing to stdout, so there's nothing to cache; and when
+	// watching, the script must run so its dependencies are recorded.
+	// (Runs that call plugins, which may give different answers each
+	// time, are not stored by the run cache.)
+	if generateOptions.emitDependencies != "" || generateOptions.stdout || generateOptions.recordFile != "" {
+		generateOptions.incremental = false
+	}
+
+	runScript := func(vm *vm) error {
+		return vm.Run("@jkcfg/std/cmd/<generate>", fmt.Sprintf(string(std.Module("cmd/generate-module.js")), args[0]))
 	}
 
 	vm := newVM(&generateOptions.vmOptions, ".")
 	vm.parameters.SetBool("jk.generate.stdout", generateOptions.stdout)
 
-	if err := vm.Run("@jkcfg/std/cmd/<generate>", fmt.Sprintf(string(std.Module("cmd/generate-module.js")), args[0])); err != nil {
+	var (
+		runs   *runcache.Cache
+		runKey string
+	)
+	if vm.incremental {
+		runs = runcache.New(filepath.Join(vm.cacheDir, "runs"))
+		runKey = generateRunKey(filename, &vm.vmOptions)
+		written, ok, err := runs.Restore(runKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if ok {
+			if vm.verbose {
+				for _, p := range written {
+					fmt.Printf("write %s (from run cache)\n", p)
+				}
+			}
+			return
+		}
+	}
+
+	checkDeclaredParams(vm.vmOptions, ".", runScript)
+	if err := runScript(vm); err != nil {
 		if !skipException(err) {
 			log.Fatal(err)
 		}
 		os.Exit(1)
 	}
+
+	if runs != nil {
+		if err := runs.Store(runKey, vm.recorder); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not update run cache: %v\n", err)
+		}
+	}
+}
+
+// generateRunKey computes the run cache key for generating from the
+// script given: everything that affects the result, other than the
+// files used by the script (which are checked by the cache).
+func generateRunKey(filename string, opts *vmOptions) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	script, err := filepath.Abs(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	params, err := json.Marshal(opts.parameters)
+	if err != nil {
+		log.Fatal(err)
+	}
+	libs := make([]string, len(opts.libraryImages))
+	for i := range opts.libraryImages {
+		libs[i] = opts.libraryImages[i].String()
+	}
+	plugins := make([]string, len(opts.plugins))
+	for i, p := range opts.plugins {
+		plugins[i] = p.name + "=" + p.path
+	}
+	// The commands allowed don't matter, since runs that execute
+	// commands aren't stored; but the environment variables allowed
+	// do, since a run restored may have looked up one no longer
+	// allowed.
+	return runcache.Key(buildID(), cwd, script, opts.inputDirectory, opts.outputDirectory, opts.importMap, strings.Join(libs, " "), string(params), strings.Join(plugins, " "), strings.Join(opts.envAllow, " "), fmt.Sprint(opts.strictParams))
 }
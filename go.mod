@@ -1,12 +1,16 @@
 module github.com/jkcfg/jk
 
 require (
+	github.com/evanw/esbuild v0.19.11
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/ghodss/yaml v1.0.0
+	github.com/go-sourcemap/sourcemap v2.1.4+incompatible
 	github.com/google/flatbuffers v1.11.0
 	github.com/google/go-containerregistry v0.0.0-20200128171736-43a8003f9213
 	github.com/hashicorp/hcl v1.0.0
 	github.com/jkcfg/v8worker2 v0.0.0-20191022163158-90e467066938
 	github.com/opencontainers/image-spec v1.0.1
+	github.com/peterh/liner v1.2.0
 	github.com/pkg/errors v0.8.1
 	github.com/shurcooL/httpfs v0.0.0-20190707220628-8d4bc4ba7749
 	github.com/shurcooL/vfsgen v0.0.0-20181202132449-6a9ea43bcacd
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jkcfg/jk/pkg/std"
+)
+
+var paramsCmd = &cobra.Command{
+	Use:     "params <script>",
+	Example: paramsExamples,
+	Short:   "List the parameters a script declares",
+	Args:    cobra.ExactArgs(1),
+	Run:     params,
+	Long: `List the parameters a script declares with param.declare, by running it
+without writing files or running commands.
+
+jk run and jk generate run a script the same way, before running it
+for real, to check the parameters supplied against those declared.`,
+}
+
+const paramsExamples = `
+  listing the parameters declared with param.declare by a script, and the modules it imports
+    jk params ./deployment.js
+
+  as JSON, including any JSON Schema for each parameter
+    jk params --format json ./deployment.js
+`
+
+var paramsOptions struct {
+	vmOptions
+	format string
+}
+
+func init() {
+	paramsOptions.parameters = std.NewParams()
+	initInputFlags(paramsCmd, &paramsOptions.vmOptions)
+	initModuleFlags(paramsCmd, &paramsOptions.vmOptions)
+	paramsCmd.PersistentFlags().StringVar(&paramsOptions.format, "format", "text", "output format: text or json")
+	jk.AddCommand(paramsCmd)
+}
+
+func params(cmd *cobra.Command, args []string) {
+	switch paramsOptions.format {
+	case "text", "json":
+	default:
+		log.Fatalf("params: unknown format %q (expected text or json)", paramsOptions.format)
+	}
+
+	filename := args[0]
+	scriptDir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		log.Fatal(err)
+	}
+	applyProjectConfig(cmd, &paramsOptions.vmOptions, scriptDir)
+	// The script is run to find what it declares; but nothing is
+	// written, and the parameters aren't checked.
+	paramsOptions.listParams = true
+	vm := newVM(&paramsOptions.vmOptions, scriptDir)
+	err = vm.RunFile(filename)
+	decls := vm.runtime.ParamDeclarations()
+	if err != nil {
+		// The script may well fail after declaring its parameters,
+		// e.g., because it runs a command; what it declared is
+		// still listed.
+		if len(decls) == 0 {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "warning: %s failed after declaring parameters: %v\n", filename, err)
+	}
+	if paramsOptions.format == "json" {
+		if decls == nil {
+			decls = []std.ParamDeclaration{}
+		}
+		data, err := json.MarshalIndent(decls, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PARAMETER\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, decl := range decls {
+		var def string
+		switch {
+		case decl.Required:
+			def = "(required)"
+		case decl.Default != nil:
+			data, _ := json.Marshal(decl.Default)
+			def = string(data)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", decl.Path, decl.Type, def, decl.Description)
+	}
+	w.Flush()
+}
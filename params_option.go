@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/jkcfg/jk/pkg/std"
@@ -50,9 +52,22 @@ func (p *paramsOption) setFromFile(s string) error {
 
 func (p *paramsOption) setFromCommandline(s string) error {
 	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected name=value or name:=<json>, got %q", s)
+	}
 	path := parts[0]
 	v := parts[1]
 
+	// name:=<json> sets a value of any type, given as JSON.
+	if strings.HasSuffix(path, ":") {
+		var value interface{}
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			return fmt.Errorf("%s: invalid JSON value: %v", path, err)
+		}
+		p.params.Set(strings.TrimSuffix(path, ":"), value)
+		return nil
+	}
+
 	p.params.SetString(path, v)
 	return nil
 }
@@ -64,6 +79,18 @@ func (p *paramsOption) Set(s string) error {
 	return p.setFromCommandline(s)
 }
 
+// applyParamsFromEnv sets the parameters from environment variables,
+// if a prefix was given with --params-from-env. These are beneath any
+// given with -f or -p, whatever the order on the command line.
+func applyParamsFromEnv(opts *vmOptions) {
+	if opts.paramsFromEnv == "" {
+		return
+	}
+	params := std.NewParamsFromEnv(opts.paramsFromEnv, os.Environ())
+	params.Merge(opts.parameters)
+	opts.parameters = params
+}
+
 func (p *paramsOption) Type() string {
 	if p.source == paramSourceFile {
 		return "filename"
@@ -50,6 +50,21 @@ func readAsCSV(val string) ([]string, error) {
 	return csvReader.Read()
 }
 
+// ParseImageRef parses an image reference as given to `--lib`. The
+// reference must include a tag or digest, and may not use the tag
+// `latest`.
+func ParseImageRef(s string) (name.Reference, error) {
+	r, err := name.ParseReference(s)
+	if err != nil {
+		return nil, err
+	}
+	// we want either a tag or a digest
+	if r.Identifier() == "latest" {
+		return nil, fmt.Errorf("image ref has no tag or digest, or uses 'latest'")
+	}
+	return r, nil
+}
+
 // String returns a string representation of the value
 func (value *ImageRefSliceValue) String() string {
 	str, _ := writeRefsAsCsv(*value.refs)
@@ -67,15 +82,10 @@ func (value *ImageRefSliceValue) Set(v string) error {
 
 	out := make([]name.Reference, len(strs), len(strs))
 	for i := range strs {
-		r, err := name.ParseReference(strs[i])
+		r, err := ParseImageRef(strs[i])
 		if err != nil {
 			return err
 		}
-		// we want either a tag or a digest
-		if r.Identifier() == "latest" {
-			return fmt.Errorf("image ref has no tag or digest, or uses 'latest'")
-		}
-
 		out[i] = r
 	}
 
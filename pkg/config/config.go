@@ -0,0 +1,156 @@
+// Package config has code for finding and loading project
+// configuration files. A project configuration file supplies defaults
+// for the flags given to jk, so that they don't need to be repeated
+// for every invocation in a project.
+//
+// The file is named `jk.yaml`, and is found by looking in the
+// directory of the script being run, then each of its parent
+// directories in turn. It looks like this:
+//
+//     lib:
+//       - jkcfg/kubernetes:0.6.0
+//     parameters:
+//       - params.yaml
+//     inputDirectory: ./config
+//     outputDirectory: ./out
+//     cache: ./.jk
+//     importMap: ./import-map.json
+//     allowExec:
+//       - git
+//     envAllow:
+//       - CI_*
+//     strictParams: true
+//
+// Relative paths are taken to be relative to the directory containing
+// the configuration file.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+)
+
+// Filename is the name of the project configuration file.
+const Filename = "jk.yaml"
+
+// Config is the content of a project configuration file.
+type Config struct {
+	// Path is the (absolute) path to the file the configuration was
+	// loaded from.
+	Path string `json:"-"`
+
+	// Libraries are image references to put in the module search
+	// path, as with `--lib`.
+	Libraries []string `json:"lib,omitempty"`
+	// Parameters are files to load parameters from, as with `-f`.
+	Parameters []string `json:"parameters,omitempty"`
+	// InputDirectory is the directory to read files from, as with `-i`.
+	InputDirectory string `json:"inputDirectory,omitempty"`
+	// OutputDirectory is the directory to write files to, as with
+	// `-o`.
+	OutputDirectory string `json:"outputDirectory,omitempty"`
+	// Cache is the directory to use for caching images, as with
+	// `--cache`.
+	Cache string `json:"cache,omitempty"`
+	// ImportMap is a file containing an import map, as with
+	// `--import-map`.
+	ImportMap string `json:"importMap,omitempty"`
+	// AllowExec are the commands scripts may run with std.exec, as
+	// with `--allow-exec`.
+	AllowExec []string `json:"allowExec,omitempty"`
+	// EnvAllow are patterns matching the environment variables
+	// scripts may look up with std.env, as with `--env-allow`.
+	EnvAllow []string `json:"envAllow,omitempty"`
+	// StrictParams makes it an error to supply parameters that
+	// scripts don't declare, as with `--strict-params`.
+	StrictParams bool `json:"strictParams,omitempty"`
+}
+
+// Find looks for a project configuration file in the directory given
+// and then each of its parents, returning the path of the first
+// found. If there is no configuration file, the empty string is
+// returned.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		p := filepath.Join(dir, Filename)
+		info, err := os.Stat(p)
+		switch {
+		case err == nil && !info.IsDir():
+			return p, nil
+		case err != nil && !os.IsNotExist(err):
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads the project configuration file at the path given. Paths
+// in the configuration are made absolute, relative to the directory
+// containing the file.
+func Load(path string) (*Config, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	config.Path = path
+	config.resolvePaths(filepath.Dir(path))
+	return config, nil
+}
+
+// parse decodes the YAML given as a Config, rejecting any fields it
+// doesn't know about (they are most likely typos).
+func parse(data []byte) (*Config, error) {
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if len(bytes.TrimSpace(j)) == 0 || string(j) == "null" {
+		return config, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func resolvePath(dir, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+func (c *Config) resolvePaths(dir string) {
+	for i := range c.Parameters {
+		c.Parameters[i] = resolvePath(dir, c.Parameters[i])
+	}
+	c.InputDirectory = resolvePath(dir, c.InputDirectory)
+	c.OutputDirectory = resolvePath(dir, c.OutputDirectory)
+	c.Cache = resolvePath(dir, c.Cache)
+	c.ImportMap = resolvePath(dir, c.ImportMap)
+}
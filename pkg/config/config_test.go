@@ -0,0 +1,54 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFind(t *testing.T) {
+	expected, err := filepath.Abs("testfiles/project/jk.yaml")
+	assert.NoError(t, err)
+
+	for _, dir := range []string{
+		"testfiles/project",
+		"testfiles/project/scripts",
+		"testfiles/project/scripts/nested",
+	} {
+		t.Run(dir, func(t *testing.T) {
+			found, err := Find(dir)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, found)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := filepath.Abs("testfiles/project")
+	assert.NoError(t, err)
+
+	config, err := Load("testfiles/project/jk.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{
+		Path:            filepath.Join(dir, "jk.yaml"),
+		Libraries:       []string{"jkcfg/kubernetes:0.6.0"},
+		Parameters:      []string{filepath.Join(dir, "params.yaml"), "/etc/params.json"},
+		OutputDirectory: filepath.Join(dir, "out"),
+		Cache:           filepath.Join(dir, ".jk"),
+		AllowExec:       []string{"git"},
+		EnvAllow:        []string{"CI_*"},
+		StrictParams:    true,
+	}, config)
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	_, err := Load("testfiles/typo.yaml")
+	assert.Error(t, err)
+}
+
+func TestParseEmpty(t *testing.T) {
+	config, err := parse([]byte(""))
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{}, config)
+}
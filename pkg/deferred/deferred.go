@@ -18,11 +18,16 @@ import (
 )
 
 var (
-	globalDeferreds = newDeferreds()
+	globalDeferreds = NewScheduler()
 )
 
-func newDeferreds() *deferreds {
-	d := &deferreds{
+// NewScheduler creates a scheduler for deferred values. Each VM should
+// have its own (rather than using the global scheduler), if more than
+// one is run at a time; otherwise, waiting for the deferred values of
+// one VM also waits for those of the others, and values are resolved
+// in the order they were registered across all of them.
+func NewScheduler() *Scheduler {
+	d := &Scheduler{
 		serial: 1,
 	}
 	d.serialCond = sync.NewCond(&d.serialMu)
@@ -45,11 +50,14 @@ func Wait() {
 // JavaScript.
 type Serial uint64
 
+// Scheduler performs the actions for deferred values, and sends their
+// results.
+//
 // To enforce determinism, we resolve deferred in the same order they are
 // created. This is done through resolvedSerial that stores what was the last
 // deferred resolved and we use a sync.Cond to handle synchronization between
 // goroutines servicing the deferred.
-type deferreds struct {
+type Scheduler struct {
 	serialMu       sync.Mutex
 	serial         Serial
 	serialCond     *sync.Cond
@@ -58,7 +66,7 @@ type deferreds struct {
 	outstanding sync.WaitGroup
 }
 
-func (d *deferreds) waitForSerial(s Serial) {
+func (d *Scheduler) waitForSerial(s Serial) {
 	d.serialMu.Lock()
 	defer d.serialMu.Unlock()
 
@@ -70,7 +78,7 @@ func (d *deferreds) waitForSerial(s Serial) {
 	}
 }
 
-func (d *deferreds) serialResolved(s Serial) {
+func (d *Scheduler) serialResolved(s Serial) {
 	d.serialMu.Lock()
 	d.resolvedSerial = s
 	d.serialMu.Unlock()
@@ -89,7 +97,7 @@ type performFunc func() ([]byte, error)
 
 // Register adds a request to those being tracked, and returns the
 // serial number to give back to the runtime.
-func (d *deferreds) Register(perform performFunc, r resolver) Serial {
+func (d *Scheduler) Register(perform performFunc, r resolver) Serial {
 	d.serialMu.Lock()
 	s := d.serial
 	d.serial++
@@ -116,6 +124,6 @@ func (d *deferreds) Register(perform performFunc, r resolver) Serial {
 }
 
 // Wait blocks until all outstanding deferred requests are fulfilled.
-func (d *deferreds) Wait() {
+func (d *Scheduler) Wait() {
 	d.outstanding.Wait()
 }
@@ -0,0 +1,478 @@
+// Package jk runs jk scripts from Go. A Runtime is set up much as the
+// jk command sets up a run, but reports problems as errors rather than
+// exiting, and can keep what a script prints and writes in memory.
+//
+//	rt, err := jk.New(jk.Options{InMemory: true})
+//	if err != nil { ... }
+//	defer rt.Close()
+//	if err := rt.RunFile("config.js"); err != nil { ... }
+//	out, err := rt.Output()
+//
+// Runtimes can be used at the same time, from different goroutines;
+// each resolves its own deferred values.
+//
+// A promise that is rejected and not handled still ends the process,
+// having been reported to Options.Stderr; this is how V8 is set up.
+package jk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/jkcfg/jk/pkg/deferred"
+	"github.com/jkcfg/jk/pkg/image"
+	"github.com/jkcfg/jk/pkg/image/cache"
+	"github.com/jkcfg/jk/pkg/record"
+	"github.com/jkcfg/jk/pkg/resolve"
+	"github.com/jkcfg/jk/pkg/stacktrace"
+	"github.com/jkcfg/jk/pkg/std"
+	"github.com/jkcfg/jk/pkg/vfs"
+	v8 "github.com/jkcfg/v8worker2"
+)
+
+// PublicModules are the std modules scripts are allowed to import.
+var PublicModules = []string{"index.js", "param.js", "fs.js", "merge.js", "debug.js", "schema.js", "test.js", "plugin.js"}
+
+// Options configure a Runtime.
+type Options struct {
+	// ScriptDirectory is the directory relative to which modules are
+	// resolved. It defaults to the current directory.
+	ScriptDirectory string
+	// InputDirectory is where files read by scripts are found. It
+	// defaults to ScriptDirectory.
+	InputDirectory string
+	// OutputDirectory is where files written by scripts go. It is
+	// ignored if InMemory is set.
+	OutputDirectory string
+	// CacheDirectory is used for downloaded images and compiled
+	// TypeScript. It defaults to a jk directory in the user's cache
+	// directory.
+	CacheDirectory string
+	// Libraries are images to find modules in, downloaded if
+	// necessary.
+	Libraries []string
+	// ImportMap is the path of a JSON file used to rewrite import
+	// specifiers.
+	ImportMap string
+	// Parameters are the parameters scripts can look up.
+	Parameters std.Params
+	// Verbose makes writes and reads be printed as they are done.
+	Verbose bool
+	// DryRun stops files from being written.
+	DryRun bool
+	// Commands are the commands scripts may run with std.exec; none
+	// may be run unless given here.
+	Commands []string
+	// EnvAllow are patterns matching the environment variables
+	// scripts may look up with std.env; none may be looked up unless
+	// matched here.
+	EnvAllow []string
+	// StrictParams makes it an error to supply parameters that the
+	// scripts run don't declare.
+	StrictParams bool
+	// SkipParamValidation stops the parameters supplied from being
+	// checked against those the scripts declare; the declarations
+	// are only collected, to be returned by ParamDeclarations.
+	SkipParamValidation bool
+	// Recorder, if not nil, records the files and modules used and
+	// the files written.
+	Recorder *record.Recorder
+
+	// InMemory keeps the files written and what's printed in memory,
+	// to be returned by Runtime.Output, rather than writing them to
+	// OutputDirectory and Stdout.
+	InMemory bool
+	// Stdin is what scripts read as stdin, Stdout is where they
+	// print, and Stderr is where rejected promises are reported. They
+	// default to those of the process.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Output is what has been printed and written by the scripts run in
+// a Runtime with InMemory set.
+type Output struct {
+	Stdout []byte
+	// Files are the contents of the files written, keyed by their
+	// slash-separated path relative to the output directory (or for
+	// files written to the host by path, their absolute path).
+	Files map[string][]byte
+}
+
+// Runtime runs scripts. Scripts run in the same Runtime share a
+// global scope.
+type Runtime struct {
+	opts Options
+
+	scriptDir         string
+	inputDir          string
+	moduleFilesystems []vfs.FileSystem
+	imageCache        *cache.Cache
+	importMap         *resolve.ImportMap
+	sources           *stacktrace.Sources
+
+	worker    *v8.Worker
+	std       *std.Std
+	resources *std.ModuleResources
+	methods   map[string]std.RPCFunc
+
+	// each runtime resolves its own deferred values, so runtimes
+	// used at the same time don't wait on each other
+	deferreds *deferred.Scheduler
+
+	// when InMemory is set, this keeps what's written
+	memory *std.MemorySink
+}
+
+// errorHandler is called by V8 when a promise is rejected and not
+// handled. It sends the error back to be reported by
+// Runtime.reportRejection (prefixed with errorReportPrefix, so it can
+// be distinguished from std messages), since the stack needs to be
+// rewritten using source maps.
+const errorHandler = `
+function onerror(msg, src, line, col, err) {
+  const stack = (err && err.stack) ? err.stack : String(err);
+  try {
+    const report = unescape(encodeURIComponent(JSON.stringify({ src, stack })));
+    const prefix = '\0\0\0\0jk:onerror\0';
+    const bytes = new Uint8Array(prefix.length + report.length);
+    for (let i = 0; i < prefix.length; i++) bytes[i] = prefix.charCodeAt(i);
+    for (let i = 0; i < report.length; i++) bytes[prefix.length + i] = report.charCodeAt(i);
+    V8Worker2.send(bytes.buffer);
+  } catch (e) {
+    V8Worker2.log("Promise rejected at", src, line + ":" + col);
+    V8Worker2.log(stack);
+  }
+}
+`
+
+// errorReportPrefix marks a message sent by errorHandler. A
+// flatbuffer can't start with a zero offset, so this won't be
+// mistaken for a std message.
+var errorReportPrefix = []byte("\x00\x00\x00\x00jk:onerror\x00")
+
+const global = `
+var global = {};
+`
+
+// New creates a Runtime with the options given. It should be closed
+// when it's no longer needed.
+func New(opts Options) (*Runtime, error) {
+	r := &Runtime{
+		opts:      opts,
+		resources: std.NewModuleResources(),
+		sources:   stacktrace.NewSources(),
+		methods:   map[string]std.RPCFunc{},
+	}
+
+	scriptDir := opts.ScriptDirectory
+	if scriptDir == "" {
+		scriptDir = "."
+	}
+	var err error
+	if r.scriptDir, err = filepath.Abs(scriptDir); err != nil {
+		return nil, err
+	}
+	inputDir := scriptDir
+	if opts.InputDirectory != "" {
+		inputDir = opts.InputDirectory
+	}
+	if r.inputDir, err = filepath.Abs(inputDir); err != nil {
+		return nil, err
+	}
+
+	if r.opts.CacheDirectory == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			fmt.Fprintf(r.stderr(), "cannot determine user cache dir; using ./.jk for cache")
+			r.opts.CacheDirectory = "./.jk"
+		} else {
+			r.opts.CacheDirectory = filepath.Join(userCache, "jk")
+		}
+	}
+	r.imageCache = cache.New(r.opts.CacheDirectory)
+
+	if opts.ImportMap != "" {
+		importMap, err := resolve.LoadImportMap(opts.ImportMap)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load import map")
+		}
+		r.importMap = importMap
+	}
+
+	for _, lib := range opts.Libraries {
+		imgVfs, err := r.imageCache.EnsureImage(lib)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to fetch image %q", lib)
+		}
+		r.moduleFilesystems = append(r.moduleFilesystems, vfs.Chroot(imgVfs, image.ModulesDir))
+	}
+
+	outputDir := opts.OutputDirectory
+	var output std.OutputSink = std.HostSink{Stdout: opts.Stdout}
+	if opts.InMemory {
+		r.memory = std.NewMemorySink()
+		outputDir, output = "", r.memory
+	}
+
+	parameters := opts.Parameters
+	if parameters == nil {
+		parameters = std.NewParams()
+	}
+	r.deferreds = deferred.NewScheduler()
+	r.std = std.NewStd(std.Options{
+		Verbose:    opts.Verbose,
+		Parameters: parameters,
+		Sandbox: std.Sandbox{
+			Base:      resolve.ScriptBase(r.inputDir),
+			WriteRoot: outputDir,
+			Modules:   r.resources,
+			Recorder:  opts.Recorder,
+			Stdin:     opts.Stdin,
+		},
+		DryRun:     opts.DryRun,
+		ExtMethods: r.methods,
+		Commands:   opts.Commands,
+		EnvAllow:   opts.EnvAllow,
+		Output:     output,
+		Deferreds:  r.deferreds,
+
+		StrictParams:        opts.StrictParams,
+		SkipParamValidation: opts.SkipParamValidation,
+	})
+
+	r.worker = v8.New(r.onMessageReceived)
+	if err := r.worker.Load("errorHandler", errorHandler); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if err := r.worker.Load("global", global); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Close releases the resources used by the runtime.
+func (r *Runtime) Close() error {
+	r.worker.Dispose()
+	return nil
+}
+
+// RegisterMethod makes the function given callable from scripts as
+// the RPC method named. The std methods take precedence over those
+// registered.
+func (r *Runtime) RegisterMethod(name string, fn std.RPCFunc) {
+	r.methods[name] = fn
+}
+
+// ScriptDirectory is the (absolute) directory relative to which
+// modules are resolved.
+func (r *Runtime) ScriptDirectory() string {
+	return r.scriptDir
+}
+
+// CacheDirectory is the directory used for caching.
+func (r *Runtime) CacheDirectory() string {
+	return r.opts.CacheDirectory
+}
+
+func (r *Runtime) stderr() io.Writer {
+	if r.opts.Stderr == nil {
+		return os.Stderr
+	}
+	return r.opts.Stderr
+}
+
+func (r *Runtime) onMessageReceived(msg []byte) []byte {
+	if bytes.HasPrefix(msg, errorReportPrefix) {
+		r.reportRejection(msg[len(errorReportPrefix):])
+		return nil
+	}
+	return r.std.Execute(msg, r.worker)
+}
+
+// reportRejection prints an error sent by errorHandler, with the
+// stack trace rewritten to refer to original sources, and a code
+// frame for where the error was thrown.
+func (r *Runtime) reportRejection(data []byte) {
+	stderr := r.stderr()
+	var report struct {
+		Src   string `json:"src"`
+		Stack string `json:"stack"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		fmt.Fprintf(stderr, "Promise rejected (could not decode error: %v)\n", err)
+		return
+	}
+	fmt.Fprintf(stderr, "Promise rejected at %s\n", report.Src)
+	if pos, ok := stacktrace.TopFrame(report.Stack); ok {
+		fmt.Fprint(stderr, r.sources.Frame(pos))
+	}
+	fmt.Fprintln(stderr, r.sources.RewriteStack(report.Stack))
+}
+
+// sourceError rewrites an error from loading a module so that it
+// refers to the original source.
+func (r *Runtime) sourceError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.New(r.sources.FormatException(err.Error()))
+}
+
+// Resolver returns a resolver for loading modules into the runtime.
+func (r *Runtime) Resolver() *resolve.Resolver {
+	return r.NewResolver(r.worker)
+}
+
+// NewResolver creates a resolver using the given loader, which is
+// usually the runtime itself (see Resolver); the importers are set up
+// according to the runtime's options.
+func (r *Runtime) NewResolver(loader resolve.Loader) *resolve.Resolver {
+	hostFs := vfs.User("file://", http.Dir("/"))
+	workingDir := vfs.Location{Vfs: hostFs, Path: r.inputDir}
+	hostModule, hostModulePath := r.resources.MakeResourceModule(std.ModuleAccess{
+		Loc:                      workingDir,
+		AllowPathsOutsideSandbox: true,
+		AllowWriteToHost:         true,
+	})
+	makeHostModule := func(_ vfs.Location) ([]byte, string) {
+		return hostModule, hostModulePath
+	}
+
+	makeResourceModule := func(loc vfs.Location) ([]byte, string) {
+		return r.resources.MakeResourceModule(std.ModuleAccess{
+			Loc: loc,
+		})
+	}
+
+	importers := []resolve.Importer{
+		&resolve.Relative{},
+		&resolve.MagicImporter{
+			Specifier: resolve.ResourceModule,
+			Generate:  makeResourceModule,
+			Public:    true,
+		},
+		&resolve.MagicImporter{Specifier: "@jkcfg/std/internal/host", Generate: makeHostModule},
+		&resolve.StdImporter{
+			PublicModules: PublicModules,
+		},
+		resolve.NewImageImporter(r.imageCache),
+		resolve.NewFileImporter(vfs.User(r.scriptDir, http.Dir(r.scriptDir))),
+		resolve.NewNodeImporter(vfs.User(r.scriptDir, http.Dir(r.scriptDir))),
+	}
+
+	for _, fs := range r.moduleFilesystems {
+		importers = append(importers, resolve.NewFileImporter(fs))
+	}
+
+	resolver := resolve.NewResolver(loader, resolve.ScriptBase(r.scriptDir), importers...)
+	resolver.SetRecorder(r.opts.Recorder)
+	resolver.SetImportMap(r.importMap)
+	resolver.SetSources(r.sources)
+	resolver.SetStderr(r.stderr())
+	resolver.SetTranslator(".ts", &resolve.TypeScriptTranslator{
+		CacheDir: filepath.Join(r.opts.CacheDirectory, "typescript"),
+	})
+	return resolver
+}
+
+// Load runs the code given as a classic script (rather than a module),
+// so that its declarations are global.
+func (r *Runtime) Load(name, code string) error {
+	err := r.worker.Load(name, code)
+	r.deferreds.Wait()
+	return err
+}
+
+// Run runs the source given as a module, named by specifier, and
+// waits for anything it started to finish.
+func (r *Runtime) Run(specifier string, source string) error {
+	r.sources.Add(specifier, source, nil)
+	err := r.worker.LoadModule(specifier, source, r.Resolver().ResolveModule)
+	r.deferreds.Wait()
+	return r.finish(err)
+}
+
+// RunModule runs the module found by resolving specifier relative to
+// referrer, as an import would be.
+func (r *Runtime) RunModule(specifier string, referrer string) error {
+	_, ret := r.Resolver().ResolveModule(specifier, referrer)
+	r.deferreds.Wait()
+	if ret != 0 {
+		err := fmt.Errorf("unable to load module %q", specifier)
+		return errors.Wrap(err, "run-module")
+	}
+	return r.finish(nil)
+}
+
+// RunFile runs the script in the file given, as a module.
+func (r *Runtime) RunFile(filename string) error {
+	// Add the script to the list of dependencies.
+	if r.opts.Recorder != nil {
+		abspath, _ := filepath.Abs(filename)
+		r.opts.Recorder.Record(record.ImportFile, record.Params{
+			"specifier": filename,
+			"path":      abspath,
+		})
+	}
+	input, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	resolver := r.Resolver()
+	script := vfs.Location{Vfs: vfs.User(r.scriptDir, http.Dir(r.scriptDir)), Path: filepath.Base(filename)}
+	source, err := resolver.Translate(script, input)
+	if err != nil {
+		return err
+	}
+	r.sources.Add(filepath.Base(filename), string(source), func(p string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(filepath.Dir(filename), p))
+	})
+	err = r.worker.LoadModule(filepath.Base(filename), string(source), resolver.ResolveModule)
+	r.deferreds.Wait()
+	return r.finish(err)
+}
+
+// finish gives the error from a run, if there was one, or otherwise
+// checks that the parameters supplied were all declared (if that's
+// required).
+func (r *Runtime) finish(err error) error {
+	if err != nil {
+		return r.sourceError(err)
+	}
+	return r.std.CheckParams()
+}
+
+// ParamDeclarations returns the parameters declared by the scripts
+// run so far.
+func (r *Runtime) ParamDeclarations() []std.ParamDeclaration {
+	return r.std.Declarations()
+}
+
+// Output returns what's been printed and written so far, for a
+// runtime with InMemory set.
+func (r *Runtime) Output() (*Output, error) {
+	if !r.opts.InMemory {
+		return nil, errors.New("output is only kept for an in-memory runtime")
+	}
+	out := &Output{
+		Stdout: r.memory.Stdout(),
+		Files:  map[string][]byte{},
+	}
+	for _, p := range r.memory.Paths() {
+		out.Files[p], _ = r.memory.File(p)
+	}
+	return out, nil
+}
@@ -0,0 +1,44 @@
+package jk
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDirectories(t *testing.T) {
+	r, err := New(Options{ScriptDirectory: "testdata", CacheDirectory: "cache"})
+	assert.NoError(t, err)
+	defer r.Close()
+
+	abs, _ := filepath.Abs("testdata")
+	assert.Equal(t, abs, r.ScriptDirectory())
+	assert.Equal(t, abs, r.inputDir)
+	assert.Equal(t, "cache", r.CacheDirectory())
+
+	_, err = r.Output()
+	assert.Error(t, err, "output is only kept in memory")
+}
+
+func TestInMemoryOutput(t *testing.T) {
+	r, err := New(Options{InMemory: true})
+	assert.NoError(t, err)
+	defer r.Close()
+
+	out, err := r.Output()
+	assert.NoError(t, err)
+	assert.Empty(t, out.Stdout)
+	assert.Empty(t, out.Files)
+
+	r.memory.Write("a/b.json", 0, []byte("{}\n"), 0644)
+	out, err = r.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"a/b.json": []byte("{}\n")}, out.Files)
+}
+
+func TestNewImportMapError(t *testing.T) {
+	_, err := New(Options{ImportMap: "testdata/does-not-exist.json"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to load import map")
+}
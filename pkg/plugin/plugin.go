@@ -0,0 +1,284 @@
+// Package plugin runs RPC plugins: programs that extend the RPC
+// methods available to scripts, without being compiled into jk.
+//
+// A plugin is started as a subprocess, and spoken to in JSON-RPC 2.0,
+// with one JSON value per line, requests on its stdin and responses
+// on its stdout. It is first asked to describe itself:
+//
+//     --> {"jsonrpc":"2.0","id":0,"method":"describe"}
+//     <-- {"jsonrpc":"2.0","id":0,"result":{"methods":["lookup"]}}
+//
+// then each method it names can be called, with the arguments given
+// in the script as params:
+//
+//     --> {"jsonrpc":"2.0","id":1,"method":"lookup","params":["web-1"]}
+//     <-- {"jsonrpc":"2.0","id":1,"result":{"owner":"team-a"}}
+//
+// Errors are reported as usual for JSON-RPC, with an "error" object
+// having a "message". Anything a plugin writes to stderr is kept, to
+// be shown if it crashes. A plugin should exit when its stdin is
+// closed.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DescribeMethod is the method a plugin is asked to describe itself
+// with.
+const DescribeMethod = "describe"
+
+// Plugin is a running plugin.
+type Plugin struct {
+	name    string
+	methods []string
+	timeout time.Duration
+
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan response
+	stderr    *tail
+
+	mu     sync.Mutex // serialises calls
+	nextID int
+	// closed when the plugin has exited (or been killed), after which
+	// err says why
+	exited chan struct{}
+	err    error
+
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params,omitempty"`
+}
+
+type response struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Start starts the plugin program at path, with the name given, and
+// asks it for the methods it has. Each call to the plugin (including
+// the first) fails if it takes longer than timeout.
+func Start(name, path string, timeout time.Duration) (*Plugin, error) {
+	p := &Plugin{
+		name:      name,
+		timeout:   timeout,
+		cmd:       exec.Command(path),
+		responses: make(chan response),
+		stderr:    &tail{max: 4096},
+		exited:    make(chan struct{}),
+		closing:   make(chan struct{}),
+	}
+	p.cmd.Stderr = p.stderr
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	p.stdin = stdin
+	if err := p.cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "plugin %s", name)
+	}
+	go p.read(stdout)
+
+	result, err := p.call(DescribeMethod, nil)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	var description struct {
+		Methods []string `json:"methods"`
+	}
+	if err := json.Unmarshal(result, &description); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("plugin %s: could not decode description: %v", name, err)
+	}
+	p.methods = description.Methods
+	return p, nil
+}
+
+// read decodes responses from the plugin until it exits, or sends
+// something that can't be decoded.
+func (p *Plugin) read(stdout io.Reader) {
+	dec := json.NewDecoder(stdout)
+	var err error
+	for {
+		var r response
+		if err = dec.Decode(&r); err != nil {
+			break
+		}
+		select {
+		case p.responses <- r:
+		case <-p.closing:
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	} else {
+		err = fmt.Errorf("could not decode response: %v", err)
+		p.cmd.Process.Kill()
+	}
+	if waitErr := p.cmd.Wait(); err == nil {
+		err = waitErr
+	}
+	if err == nil {
+		err = errors.New("exited")
+	}
+	p.err = err
+	close(p.exited)
+}
+
+// Name is the name the plugin was started with.
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Methods are the methods the plugin has.
+func (p *Plugin) Methods() []string {
+	return p.methods
+}
+
+// crashed gives an error saying the plugin has stopped, including what
+// it last wrote to stderr.
+func (p *Plugin) crashed() error {
+	msg := fmt.Sprintf("plugin %s stopped: %v", p.name, p.err)
+	if s := strings.TrimSpace(p.stderr.String()); s != "" {
+		msg += "\n" + s
+	}
+	return errors.New(msg)
+}
+
+func (p *Plugin) call(method string, params []interface{}) (json.RawMessage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.exited:
+		return nil, p.crashed()
+	default:
+	}
+
+	id := p.nextID
+	p.nextID++
+	data, err := json.Marshal(request{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.stdin.Write(append(data, '\n')); err != nil {
+		// It may have stopped reading without exiting.
+		p.kill()
+		return nil, p.crashed()
+	}
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case r := <-p.responses:
+			if r.ID != id {
+				continue
+			}
+			if r.Error != nil {
+				return nil, fmt.Errorf("plugin %s: %s: %s", p.name, method, r.Error.Message)
+			}
+			return r.Result, nil
+		case <-p.exited:
+			return nil, p.crashed()
+		case <-timer.C:
+			// There's no way to know if a response will
+			// arrive later, so don't wait for one.
+			p.kill()
+			return nil, fmt.Errorf("plugin %s: %s timed out after %s", p.name, method, p.timeout)
+		}
+	}
+}
+
+// kill kills the plugin and waits until it has exited. Any responses
+// that arrive meanwhile are dropped, since read won't get to noticing
+// the exit while it's waiting to hand one over.
+func (p *Plugin) kill() {
+	p.cmd.Process.Kill()
+	for {
+		select {
+		case <-p.responses:
+		case <-p.exited:
+			return
+		}
+	}
+}
+
+// Call calls the method given, and returns its result.
+func (p *Plugin) Call(method string, args []interface{}) (interface{}, error) {
+	result, err := p.call(method, args)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &v); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Close stops the plugin, by closing its stdin and, if it hasn't
+// exited soon after, killing it.
+func (p *Plugin) Close() error {
+	p.closeOnce.Do(func() {
+		p.stdin.Close()
+		close(p.closing)
+	})
+	select {
+	case <-p.exited:
+	case <-time.After(time.Second):
+		p.cmd.Process.Kill()
+		<-p.exited
+	}
+	return nil
+}
+
+// tail keeps the last max bytes written to it.
+type tail struct {
+	mu  sync.Mutex
+	max int
+	buf bytes.Buffer
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf.Write(p)
+	if over := t.buf.Len() - t.max; over > 0 {
+		t.buf.Next(over)
+	}
+	return len(p), nil
+}
+
+func (t *tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf.String()
+}
@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The test binary doubles as a plugin, when run with
+// JK_TEST_PLUGIN set.
+func TestMain(m *testing.M) {
+	if os.Getenv("JK_TEST_PLUGIN") != "" {
+		testPlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func testPlugin() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+		var result interface{}
+		switch req.Method {
+		case DescribeMethod:
+			result = map[string]interface{}{"methods": []string{"echo", "fail", "crash", "hang", "hangup"}}
+		case "echo":
+			result = req.Params
+		case "fail":
+			fmt.Printf(`{"jsonrpc":"2.0","id":%d,"error":{"code":1,"message":"no such thing"}}`+"\n", req.ID)
+			continue
+		case "crash":
+			fmt.Fprintln(os.Stderr, "panic: something went wrong")
+			os.Exit(3)
+		case "hang":
+			time.Sleep(time.Minute)
+		case "hangup":
+			// Stop reading requests, then answer along with a
+			// response nobody asked for, and stay running.
+			os.Stdin.Close()
+			fmt.Printf(`{"jsonrpc":"2.0","id":%d,"result":null}`+"\n", req.ID)
+			fmt.Println(`{"jsonrpc":"2.0","id":-1,"result":null}`)
+			time.Sleep(time.Minute)
+		}
+		data, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		fmt.Println(string(data))
+	}
+}
+
+func startTestPlugin(t *testing.T, timeout time.Duration) *Plugin {
+	os.Setenv("JK_TEST_PLUGIN", "1")
+	defer os.Unsetenv("JK_TEST_PLUGIN")
+	p, err := Start("test", os.Args[0], timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCall(t *testing.T) {
+	p := startTestPlugin(t, 5*time.Second)
+	defer p.Close()
+
+	assert.Equal(t, "test", p.Name())
+	assert.Equal(t, []string{"echo", "fail", "crash", "hang", "hangup"}, p.Methods())
+
+	v, err := p.Call("echo", []interface{}{"a", 1.0})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", 1.0}, v)
+
+	_, err = p.Call("fail", nil)
+	assert.EqualError(t, err, "plugin test: fail: no such thing")
+
+	// still working after an error
+	v, err = p.Call("echo", []interface{}{true})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{true}, v)
+}
+
+func TestCrash(t *testing.T) {
+	p := startTestPlugin(t, 5*time.Second)
+	defer p.Close()
+
+	_, err := p.Call("crash", nil)
+	assert.EqualError(t, err, "plugin test stopped: exit status 3\npanic: something went wrong")
+	_, err = p.Call("echo", nil)
+	assert.Error(t, err)
+}
+
+func TestTimeout(t *testing.T) {
+	p := startTestPlugin(t, 100*time.Millisecond)
+	defer p.Close()
+
+	_, err := p.Call("hang", nil)
+	assert.EqualError(t, err, "plugin test: hang timed out after 100ms")
+	_, err = p.Call("echo", nil)
+	assert.Error(t, err)
+}
+
+func TestHangup(t *testing.T) {
+	p := startTestPlugin(t, 5*time.Second)
+	defer p.Close()
+
+	_, err := p.Call("hangup", nil)
+	assert.NoError(t, err)
+
+	// The plugin is still running, with a response waiting to be
+	// read; the call must not wait for it to exit by itself.
+	start := time.Now()
+	_, err = p.Call("echo", nil)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 10*time.Second, "call waited for the plugin to exit")
+}
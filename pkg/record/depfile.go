@@ -0,0 +1,113 @@
+package record
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Files returns the paths of the files the recorded operations depend
+// on (imports, reads, parameter files and so on), and the paths of
+// the files written, each in the order they were first recorded.
+func (r *Recorder) Files() (inputs, outputs []string) {
+	seenIn, seenOut := map[string]bool{}, map[string]bool{}
+	for _, op := range r.Log() {
+		p, ok := op.params["path"].(string)
+		if !ok || p == "" {
+			continue
+		}
+		if op.kind == WriteFile {
+			if !seenOut[p] {
+				seenOut[p] = true
+				outputs = append(outputs, p)
+			}
+			continue
+		}
+		if !seenIn[p] {
+			seenIn[p] = true
+			inputs = append(inputs, p)
+		}
+	}
+	return inputs, outputs
+}
+
+// EnvVars returns the names of the environment variables looked up,
+// without duplicates.
+func (r *Recorder) EnvVars() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, op := range r.Log() {
+		name, ok := op.params["name"].(string)
+		if op.kind != EnvVar || !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// WriteMake writes the recorded operations as a Makefile rule (as
+// from `gcc -MD`), with the files written as the targets, and the
+// files read as the prerequisites. Each prerequisite is also given an
+// empty rule, so make doesn't fail when one is removed. If no files
+// were written, there are no targets and nothing is written.
+func (r *Recorder) WriteMake(w io.Writer) error {
+	inputs, outputs := r.Files()
+	if len(outputs) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(mapStrings(outputs, makeEscape), " "))
+	b.WriteString(":")
+	for _, in := range inputs {
+		b.WriteString(" \\\n  ")
+		b.WriteString(makeEscape(in))
+	}
+	b.WriteString("\n")
+	for _, in := range inputs {
+		fmt.Fprintf(&b, "\n%s:\n", makeEscape(in))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteNinja writes the recorded operations as a Ninja build
+// statement, using the rule named, with the files written as the
+// outputs and the files read as implicit dependencies (so they
+// don't appear in `$in`). As with WriteMake, if no files were
+// written, nothing is written.
+func (r *Recorder) WriteNinja(w io.Writer, rule string) error {
+	inputs, outputs := r.Files()
+	if len(outputs) == 0 {
+		return nil
+	}
+
+	line := "build " + strings.Join(mapStrings(outputs, ninjaEscape), " ") + ": " + rule
+	if len(inputs) > 0 {
+		line += " | " + strings.Join(mapStrings(inputs, ninjaEscape), " ")
+	}
+	_, err := io.WriteString(w, line+"\n")
+	return err
+}
+
+func mapStrings(ss []string, f func(string) string) []string {
+	out := make([]string, len(ss))
+	for i := range ss {
+		out[i] = f(ss[i])
+	}
+	return out
+}
+
+var makeEscaper = strings.NewReplacer(" ", "\\ ", "#", "\\#", "$", "$$")
+
+func makeEscape(p string) string {
+	return makeEscaper.Replace(p)
+}
+
+var ninjaEscaper = strings.NewReplacer("$", "$$", " ", "$ ", ":", "$:", "\n", "$\n")
+
+func ninjaEscape(p string) string {
+	return ninjaEscaper.Replace(p)
+}
@@ -0,0 +1,79 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRecording() *Recorder {
+	r := &Recorder{}
+	r.Record(ParameterFile, Params{"path": "/src/params.json"})
+	r.Record(ImportFile, Params{"specifier": "main.js", "path": "/src/main.js"})
+	r.Record(ImportFile, Params{"specifier": "./lib", "path": "/src/my lib.js"})
+	r.Record(WriteFile, Params{"path": "out/a.yaml"})
+	r.Record(ReadFile, Params{"path": "/src/main.js"})
+	r.Record(WriteFile, Params{"path": "out/b$.yaml"})
+	r.Record(WriteFile, Params{"path": "out/a.yaml"})
+	return r
+}
+
+func TestFiles(t *testing.T) {
+	inputs, outputs := testRecording().Files()
+	assert.Equal(t, []string{"/src/params.json", "/src/main.js", "/src/my lib.js"}, inputs)
+	assert.Equal(t, []string{"out/a.yaml", "out/b$.yaml"}, outputs)
+}
+
+func TestEnvVars(t *testing.T) {
+	r := testRecording()
+	r.Record(EnvVar, Params{"name": "CI_COMMIT", "set": true})
+	r.Record(EnvVar, Params{"name": "CI_BRANCH", "set": false})
+	r.Record(EnvVar, Params{"name": "CI_COMMIT", "set": true})
+	assert.Equal(t, []string{"CI_COMMIT", "CI_BRANCH"}, r.EnvVars())
+	assert.Empty(t, testRecording().EnvVars())
+}
+
+func TestWriteMake(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, testRecording().WriteMake(&buf))
+	assert.Equal(t, `out/a.yaml out/b$$.yaml: \
+  /src/params.json \
+  /src/main.js \
+  /src/my\ lib.js
+
+/src/params.json:
+
+/src/main.js:
+
+/src/my\ lib.js:
+`, buf.String())
+}
+
+func TestWriteNinja(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, testRecording().WriteNinja(&buf, "jk"))
+	assert.Equal(t, "build out/a.yaml out/b$$.yaml: jk | /src/params.json /src/main.js /src/my$ lib.js\n", buf.String())
+}
+
+func TestNoOutputs(t *testing.T) {
+	r := &Recorder{}
+	r.Record(ImportFile, Params{"specifier": "main.js", "path": "/src/main.js"})
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteMake(&buf))
+	assert.NoError(t, r.WriteNinja(&buf, "jk"))
+	assert.Equal(t, "", buf.String())
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, err := json.Marshal(testRecording())
+	assert.NoError(t, err)
+
+	var r Recorder
+	assert.NoError(t, json.Unmarshal(data, &r))
+	inputs, outputs := r.Files()
+	assert.Equal(t, []string{"/src/params.json", "/src/main.js", "/src/my lib.js"}, inputs)
+	assert.Equal(t, []string{"out/a.yaml", "out/b$.yaml"}, outputs)
+}
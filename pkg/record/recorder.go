@@ -1,6 +1,9 @@
 package record
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"sync"
+)
 
 // OperationKind is the name of a recorded operation
 type OperationKind string
@@ -15,6 +18,29 @@ const (
 	ParameterFile OperationKind = "parameter-file"
 	// ReadFile is a std.read from the filesystem (exclude reading from stdin).
 	ReadFile OperationKind = "read-file"
+	// WriteFile is a std.write to the filesystem (exclude writing to stdout).
+	WriteFile OperationKind = "write-file"
+	// StatFile is a std.fileinfo for a path in the filesystem.
+	StatFile OperationKind = "stat-file"
+	// ListDirectory is a std.dir listing of a directory in the filesystem.
+	ListDirectory OperationKind = "list-directory"
+	// SchemaFile is a JSON schema read for std.validate.schemafile.
+	SchemaFile OperationKind = "schema-file"
+	// Parameter is a lookup of an input parameter with std.param, whether
+	// or not the parameter was set.
+	Parameter OperationKind = "parameter"
+	// ConfigFile is the project configuration file (jk.yaml), if one was used.
+	ConfigFile OperationKind = "config-file"
+	// ImportMapFile is the import map given with --import-map, or in the project configuration.
+	ImportMapFile OperationKind = "import-map-file"
+	// Exec is a command run with std.exec.
+	Exec OperationKind = "exec"
+	// Plugin is a call to a plugin method, with the plugin
+	// and method names.
+	Plugin OperationKind = "plugin"
+	// EnvVar is a lookup of an environment variable with std.env,
+	// whether or not it was set. Its value is not recorded.
+	EnvVar OperationKind = "env-var"
 )
 
 // Operation is an entry in the Recording.
@@ -33,23 +59,68 @@ func (o Operation) MarshalJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
 
+// UnmarshalJSON implements json.Unmarshaler.
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	kind, _ := m["kind"].(string)
+	delete(m, "kind")
+	o.kind, o.params = OperationKind(kind), m
+	return nil
+}
+
 // Recorder records Operations. It's designed to be generic, any part of jk can
-// append an operation to the log.
+// append an operation to the log. It's safe to record operations
+// concurrently, since some (e.g., reads) are completed in the
+// background.
 type Recorder struct {
+	mu  sync.Mutex
 	ops []Operation
 }
 
+// Has says whether any operation of the kind given has been recorded.
+func (r *Recorder) Has(kind OperationKind) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, op := range r.ops {
+		if op.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
 // Record appends a new operation to the log.
 func (r *Recorder) Record(kind OperationKind, params Params) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.ops = append(r.ops, Operation{kind: kind, params: params})
 }
 
 // Log retrieves the recording list of operations
 func (r *Recorder) Log() []Operation {
-	return r.ops
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Operation(nil), r.ops...)
 }
 
 // MarshalJSON implements json.Marshaler.
-func (r Recorder) MarshalJSON() ([]byte, error) {
-	return json.Marshal(r.ops)
+func (r *Recorder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Log())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, so that a recording
+// written by one process (e.g., with --emit-dependencies) can be read
+// by another.
+func (r *Recorder) UnmarshalJSON(data []byte) error {
+	var ops []Operation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ops = ops
+	return nil
 }
@@ -0,0 +1,148 @@
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+	v8 "github.com/jkcfg/v8worker2"
+)
+
+// bundleNamespace is the esbuild namespace for modules found by a
+// Resolver.
+const bundleNamespace = "jk"
+
+type bundledModule struct {
+	code    string
+	resolve v8.ModuleResolverCallback
+}
+
+// Bundler is a Loader that, rather than loading modules, collects
+// them so they can be combined into a single module. It's used with a
+// Resolver, so that the modules are found exactly as they would be
+// when running a script:
+//
+//     bundler := NewBundler()
+//     resolver := NewResolver(bundler, base, importers...)
+//     out, err := bundler.Bundle("script.js", source, resolver.ResolveModule)
+//
+// Imports of the standard library are left as they are, since
+// they're supplied by the runtime. Since @jkcfg/std/resource gives a
+// module access to the files next to it, only the entry point may
+// import it.
+//
+// A Bundler can also be used just to walk the module graph, e.g., to
+// collect a Graph of the imports.
+type Bundler struct {
+	// esbuild resolves imports concurrently; this serialises
+	// resolution, since Resolver is not safe to use concurrently.
+	mu      sync.Mutex
+	modules map[string]bundledModule
+}
+
+// NewBundler creates a new Bundler.
+func NewBundler() *Bundler {
+	return &Bundler{modules: map[string]bundledModule{}}
+}
+
+// LoadModule implements Loader, by recording the module.
+func (b *Bundler) LoadModule(name string, code string, resolve v8.ModuleResolverCallback) error {
+	// This is called (by the Resolver) with the lock held
+	b.modules[name] = bundledModule{code: code, resolve: resolve}
+	return nil
+}
+
+func (b *Bundler) module(name string) (bundledModule, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.modules[name]
+	return m, ok
+}
+
+// Bundle produces a single ES module containing the module given
+// and, transitively, all the modules it imports, other than those
+// from the standard library. The resolve callback is used for the
+// imports in the module given, usually from Resolver.ResolveModule.
+func (b *Bundler) Bundle(name, code string, resolve v8.ModuleResolverCallback) ([]byte, error) {
+	result, err := b.build(name, code, resolve, IsStdModule)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.OutputFiles) != 1 {
+		return nil, fmt.Errorf("expected a single output file from bundling, got %d", len(result.OutputFiles))
+	}
+	return result.OutputFiles[0].Contents, nil
+}
+
+// Walk resolves, transitively, all the modules imported by the module
+// given, including those from the standard library, without
+// producing a bundle.
+func (b *Bundler) Walk(name, code string, resolve v8.ModuleResolverCallback) error {
+	_, err := b.build(name, code, resolve, func(string) bool { return false })
+	return err
+}
+
+func (b *Bundler) build(name, code string, resolve v8.ModuleResolverCallback, external func(string) bool) (api.BuildResult, error) {
+	b.mu.Lock()
+	b.LoadModule(name, code, resolve)
+	b.mu.Unlock()
+
+	plugin := api.Plugin{
+		Name: "jk",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: ".*"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				if args.Kind == api.ResolveEntryPoint {
+					return api.OnResolveResult{Path: name, Namespace: bundleNamespace}, nil
+				}
+				if external(args.Path) {
+					// The resource module is generated for the
+					// module importing it, to read files next to
+					// it; once bundled, those would be looked for
+					// next to the bundle instead.
+					if args.Path == ResourceModule && args.Importer != name {
+						return api.OnResolveResult{}, fmt.Errorf("%s imports %s, so cannot be bundled (only the entry point may read its own resources)", args.Importer, ResourceModule)
+					}
+					return api.OnResolveResult{Path: args.Path, External: true}, nil
+				}
+				importer, ok := b.module(args.Importer)
+				if !ok {
+					return api.OnResolveResult{}, fmt.Errorf("unknown importing module %q", args.Importer)
+				}
+				b.mu.Lock()
+				resolved, ret := importer.resolve(args.Path, args.Importer)
+				b.mu.Unlock()
+				if ret != 0 {
+					// The resolver will have explained the failure
+					return api.OnResolveResult{}, fmt.Errorf("could not import %q", args.Path)
+				}
+				return api.OnResolveResult{Path: resolved, Namespace: bundleNamespace}, nil
+			})
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: bundleNamespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				m, ok := b.module(args.Path)
+				if !ok {
+					return api.OnLoadResult{}, fmt.Errorf("module %q was not loaded", args.Path)
+				}
+				return api.OnLoadResult{Contents: &m.code, Loader: api.LoaderJS}, nil
+			})
+		},
+	}
+
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{name},
+		Bundle:      true,
+		Format:      api.FormatESModule,
+		Platform:    api.PlatformNeutral,
+		Target:      api.ESNext,
+		Plugins:     []api.Plugin{plugin},
+		LogLevel:    api.LogLevelSilent,
+		Write:       false,
+		Outfile:     "bundle.js",
+	})
+	if len(result.Errors) > 0 {
+		msgs := api.FormatMessages(result.Errors, api.FormatMessagesOptions{Kind: api.ErrorMessage})
+		return result, errors.New(strings.TrimSpace(strings.Join(msgs, "")))
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package resolve
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundle(t *testing.T) {
+	main, err := ioutil.ReadFile("testfiles/bundle/main.js")
+	assert.NoError(t, err)
+
+	bundler := NewBundler()
+	resolver := NewResolver(bundler, ScriptBase("testfiles/bundle"), &Relative{})
+	out, err := bundler.Bundle("main.js", string(main), resolver.ResolveModule)
+	assert.NoError(t, err)
+
+	bundle := string(out)
+	// std is left to the runtime
+	assert.Contains(t, bundle, `from "@jkcfg/std"`)
+	// everything else is inlined
+	assert.NotContains(t, bundle, "./lib/greet")
+	assert.NotContains(t, bundle, "./prefix")
+	assert.Contains(t, bundle, `"hello"`)
+	assert.Contains(t, bundle, `"bundle"`)
+}
+
+func TestBundleUnresolved(t *testing.T) {
+	bundler := NewBundler()
+	resolver := NewResolver(bundler, ScriptBase("testfiles/bundle"), &Relative{})
+	_, err := bundler.Bundle("main.js", "import missing from './missing';\n", resolver.ResolveModule)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "./missing")
+	}
+}
+
+func TestBundleResources(t *testing.T) {
+	// A module that reads its own resources can't be inlined, since
+	// they would be looked for next to the bundle
+	bundler := NewBundler()
+	resolver := NewResolver(bundler, ScriptBase("testfiles/bundle"), &Relative{})
+	_, err := bundler.Bundle("main.js", "import { values } from './lib/resources';\n", resolver.ResolveModule)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "imports @jkcfg/std/resource, so cannot be bundled")
+	}
+
+	// but the entry point can
+	bundler = NewBundler()
+	resolver = NewResolver(bundler, ScriptBase("testfiles/bundle"), &Relative{})
+	out, err := bundler.Bundle("main.js", "import { read } from '@jkcfg/std/resource';\nread('values.json');\n", resolver.ResolveModule)
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), `from "@jkcfg/std/resource"`)
+}
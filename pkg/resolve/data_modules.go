@@ -0,0 +1,54 @@
+package resolve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+// JSONTranslator is a Translator for JSON files. The module it
+// produces has the parsed value as its default export, so that
+//
+//     import values from './values.json';
+//
+// is the equivalent of reading and parsing the file.
+type JSONTranslator struct{}
+
+// Translate implements Translator.
+func (JSONTranslator) Translate(resolved vfs.Location, data []byte) ([]byte, error) {
+	return dataModule(resolved, data)
+}
+
+// YAMLTranslator is a Translator for YAML files, which works as
+// JSONTranslator does. As when reading a YAML file with `std.read`,
+// only the first document in the file is used.
+type YAMLTranslator struct{}
+
+// Translate implements Translator.
+func (YAMLTranslator) Translate(resolved vfs.Location, data []byte) ([]byte, error) {
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", resolved.CanonicalPath(), err)
+	}
+	return dataModule(resolved, j)
+}
+
+// dataModule makes a module with the JSON value given as its default
+// export.
+func dataModule(resolved vfs.Location, data []byte) ([]byte, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, fmt.Errorf("%s: %v", resolved.CanonicalPath(), err)
+	}
+	var module bytes.Buffer
+	module.WriteString("export default ")
+	// JSON is not quite a subset of JavaScript; escaping U+2028 and
+	// U+2029 (along with HTML characters, harmlessly) makes it so.
+	json.HTMLEscape(&module, compact.Bytes())
+	module.WriteString(";\n")
+	return module.Bytes(), nil
+}
@@ -0,0 +1,46 @@
+package resolve
+
+import (
+	"testing"
+
+	"github.com/shurcooL/httpfs/vfsutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataModules(t *testing.T) {
+	base := ScriptBase("testfiles/data")
+
+	tests := []struct {
+		file       string
+		translator Translator
+		expected   string
+	}{
+		{"values.json", JSONTranslator{}, `export default {"name":"frontend","note":"line\u2028separator"};` + "\n"},
+		{"values.yaml", YAMLTranslator{}, `export default {"name":"frontend","replicas":3};` + "\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			data, err := vfsutil.ReadFile(base.Vfs, test.file)
+			assert.NoError(t, err)
+			loc := base
+			loc.Path = test.file
+			out, err := test.translator.Translate(loc, data)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, string(out))
+		})
+	}
+}
+
+func TestDataModuleInvalid(t *testing.T) {
+	loc := ScriptBase("/project")
+	loc.Path = "broken.json"
+	_, err := JSONTranslator{}.Translate(loc, []byte(`{"unterminated": `))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "/project/broken.json")
+	}
+
+	loc.Path = "broken.yaml"
+	_, err = YAMLTranslator{}.Translate(loc, []byte("key: [unterminated"))
+	assert.Error(t, err)
+}
@@ -14,8 +14,8 @@ import (
 // `foo/bar` will be resolved to (in the order attempted):
 //
 //   - `/foo/bar`
-//   - `/foo/bar.{js,mjs}`
-//   - `/foo/bar/index.{js,mjs}
+//   - `/foo/bar.{mjs,js,ts}`
+//   - `/foo/bar/index.{mjs,js,ts}`
 type FileImporter struct {
 	vfs vfs.FileSystem
 }
@@ -31,7 +31,7 @@ const (
 	verbatimRule        = "verbatim"
 )
 
-var moduleExtensions = []string{".mjs", ".js"}
+var moduleExtensions = []string{".mjs", ".js", ".ts"}
 
 // Import implements importer. Note that the file import only ever
 // cares to look in the root of the given filesystem. It doesn't care
@@ -71,7 +71,7 @@ func resolveFile(fs vfs.FileSystem, p string) ([]byte, vfs.Location, []Candidate
 }
 
 // resolveGuessedFile tries to apply the rule `specifier ->
-// specifier.{js,mjs} to resolve a path p within filesystem fs.
+// specifier.{mjs,js,ts} to resolve a path p within filesystem fs.
 func resolveGuessedFile(fs vfs.FileSystem, p string) ([]byte, vfs.Location, []Candidate) {
 	var candidates []Candidate
 	for _, ext := range moduleExtensions {
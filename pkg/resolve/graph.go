@@ -0,0 +1,127 @@
+package resolve
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Import is an edge in the import graph: the module Referrer
+// imported Specifier, which was resolved to the module Resolved by
+// Importer.
+type Import struct {
+	Referrer  string `json:"referrer"`
+	Specifier string `json:"specifier"`
+	// Mapped is what the specifier was mapped to by the import map,
+	// if it was.
+	Mapped   string `json:"mapped,omitempty"`
+	Resolved string `json:"resolved"`
+	Importer string `json:"importer"`
+	// Internal is true for modules supplied by the runtime, i.e.,
+	// those in the standard library and "magic" modules.
+	Internal bool `json:"internal,omitempty"`
+}
+
+// Graph collects the imports resolved by a Resolver.
+type Graph struct {
+	mu      sync.Mutex
+	seen    map[Import]bool
+	imports []Import
+}
+
+// NewGraph creates a new, empty, Graph.
+func NewGraph() *Graph {
+	return &Graph{seen: map[Import]bool{}}
+}
+
+// Add records an import. An import already recorded is ignored.
+func (g *Graph) Add(i Import) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.seen[i] {
+		return
+	}
+	g.seen[i] = true
+	g.imports = append(g.imports, i)
+}
+
+// Imports returns the imports recorded, ordered by referrer then
+// specifier.
+func (g *Graph) Imports() []Import {
+	g.mu.Lock()
+	imports := append([]Import(nil), g.imports...)
+	g.mu.Unlock()
+	sort.Slice(imports, func(i, j int) bool {
+		if imports[i].Referrer != imports[j].Referrer {
+			return imports[i].Referrer < imports[j].Referrer
+		}
+		return imports[i].Specifier < imports[j].Specifier
+	})
+	return imports
+}
+
+// Filter returns a new Graph with only the imports for which keep
+// returns true.
+func (g *Graph) Filter(keep func(Import) bool) *Graph {
+	filtered := NewGraph()
+	for _, i := range g.Imports() {
+		if keep(i) {
+			filtered.Add(i)
+		}
+	}
+	return filtered
+}
+
+func (i Import) describe() string {
+	specifier := i.Specifier
+	if i.Mapped != "" {
+		specifier = fmt.Sprintf("%s (mapped to %s)", i.Specifier, i.Mapped)
+	}
+	return fmt.Sprintf("%s -> %s [%s]", specifier, i.Resolved, i.Importer)
+}
+
+// WriteTree writes the graph as a tree, starting from the module
+// given. A module's imports are shown only the first time it appears.
+func (g *Graph) WriteTree(w io.Writer, root string) {
+	byReferrer := map[string][]Import{}
+	for _, i := range g.Imports() {
+		byReferrer[i.Referrer] = append(byReferrer[i.Referrer], i)
+	}
+	shown := map[string]bool{root: true}
+
+	var walk func(module, indent string)
+	walk = func(module, indent string) {
+		imports := byReferrer[module]
+		for n, i := range imports {
+			branch, nextIndent := "├── ", "│   "
+			if n == len(imports)-1 {
+				branch, nextIndent = "└── ", "    "
+			}
+			if shown[i.Resolved] {
+				if len(byReferrer[i.Resolved]) > 0 {
+					fmt.Fprintf(w, "%s%s%s (see above)\n", indent, branch, i.describe())
+				} else {
+					fmt.Fprintf(w, "%s%s%s\n", indent, branch, i.describe())
+				}
+				continue
+			}
+			shown[i.Resolved] = true
+			fmt.Fprintf(w, "%s%s%s\n", indent, branch, i.describe())
+			walk(i.Resolved, indent+nextIndent)
+		}
+	}
+	fmt.Fprintln(w, root)
+	walk(root, "")
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language, with
+// modules as nodes and imports as edges labelled with the specifier.
+func (g *Graph) WriteDOT(w io.Writer) {
+	fmt.Fprintln(w, "digraph imports {")
+	for _, i := range g.Imports() {
+		fmt.Fprintf(w, "  %s -> %s [label=%s];\n", strconv.Quote(i.Referrer), strconv.Quote(i.Resolved), strconv.Quote(i.Specifier))
+	}
+	fmt.Fprintln(w, "}")
+}
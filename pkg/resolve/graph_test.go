@@ -0,0 +1,83 @@
+package resolve
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+func testGraph() *Graph {
+	g := NewGraph()
+	g.Add(Import{Referrer: "main.js", Specifier: "./b", Resolved: "/b.js", Importer: "Relative"})
+	g.Add(Import{Referrer: "main.js", Specifier: "./a", Resolved: "/a.js", Importer: "Relative"})
+	g.Add(Import{Referrer: "/a.js", Specifier: "./b", Resolved: "/b.js", Importer: "Relative"})
+	g.Add(Import{Referrer: "/b.js", Specifier: "lib", Mapped: "./vendor/lib.js", Resolved: "/vendor/lib.js", Importer: "Relative"})
+	// duplicates are ignored
+	g.Add(Import{Referrer: "main.js", Specifier: "./a", Resolved: "/a.js", Importer: "Relative"})
+	return g
+}
+
+func TestGraphTree(t *testing.T) {
+	var out strings.Builder
+	testGraph().WriteTree(&out, "main.js")
+	assert.Equal(t, `main.js
+├── ./a -> /a.js [Relative]
+│   └── ./b -> /b.js [Relative]
+│       └── lib (mapped to ./vendor/lib.js) -> /vendor/lib.js [Relative]
+└── ./b -> /b.js [Relative] (see above)
+`, out.String())
+}
+
+func TestGraphDOT(t *testing.T) {
+	var out strings.Builder
+	testGraph().WriteDOT(&out)
+	assert.Equal(t, `digraph imports {
+  "/a.js" -> "/b.js" [label="./b"];
+  "/b.js" -> "/vendor/lib.js" [label="lib"];
+  "main.js" -> "/a.js" [label="./a"];
+  "main.js" -> "/b.js" [label="./b"];
+}
+`, out.String())
+}
+
+func TestGraphWalk(t *testing.T) {
+	main, err := ioutil.ReadFile("testfiles/bundle/main.js")
+	assert.NoError(t, err)
+
+	graph := NewGraph()
+	walker := NewBundler()
+	std := &MagicImporter{
+		Specifier: "@jkcfg/std",
+		Generate: func(vfs.Location) ([]byte, string) {
+			return []byte("export function log() {}"), "std.js"
+		},
+		Public: true,
+	}
+	resolver := NewResolver(walker, ScriptBase("testfiles/bundle"), &Relative{}, std)
+	resolver.SetGraph(graph)
+	assert.NoError(t, walker.Walk("main.js", string(main), resolver.ResolveModule))
+
+	var user []string
+	for _, i := range graph.Imports() {
+		if !i.Internal {
+			user = append(user, i.Referrer+" "+i.Specifier+" -> "+i.Resolved)
+		}
+	}
+	assert.Equal(t, []string{
+		"main.js ./lib/greet -> testfiles/bundle/lib/greet.js",
+		"main.js ./values.json -> testfiles/bundle/values.json",
+		"testfiles/bundle/lib/greet.js ./prefix -> testfiles/bundle/lib/prefix.js",
+	}, user)
+
+	var internal bool
+	for _, i := range graph.Imports() {
+		if i.Specifier == "@jkcfg/std" {
+			internal = i.Internal && i.Importer == "Magic"
+		}
+	}
+	assert.True(t, internal, "expected @jkcfg/std to be resolved as an internal module")
+}
@@ -0,0 +1,153 @@
+package resolve
+
+import (
+	"strings"
+
+	"github.com/jkcfg/jk/pkg/image"
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+const (
+	imageScheme     = "oci://"
+	imageRulePrefix = "via image "
+)
+
+// ImageSource is something that can supply the filesystem for a
+// container image, given its reference (e.g., `jkcfg/kubernetes:0.6.0`);
+// usually, an image cache.
+type ImageSource interface {
+	EnsureImage(image string) (vfs.FileSystem, error)
+}
+
+// ImageImporter resolves specifiers that name the image a module is
+// to be found in, as well as the path to the module within it; for
+// example,
+//
+//     oci://jkcfg/kubernetes:0.6.0/api
+//
+// refers to the module `api` in the image `jkcfg/kubernetes:0.6.0`. The
+// image reference must include a tag or a digest. The path within the
+// image is resolved in the same way as for the FileImporter, relative
+// to the modules directory of the image.
+//
+// Modules loaded from an image can also import other modules from
+// the same image, using the specifiers they would use if the image
+// were on the module search path (i.e., given with `--lib`).
+type ImageImporter struct {
+	source ImageSource
+	// image reference -> modules filesystem, so images are only
+	// fetched once per run
+	images map[string]vfs.FileSystem
+}
+
+// NewImageImporter constructs an ImageImporter that will fetch images
+// from the source given.
+func NewImageImporter(source ImageSource) *ImageImporter {
+	return &ImageImporter{
+		source: source,
+		images: map[string]vfs.FileSystem{},
+	}
+}
+
+// isImagePortSegment returns true if the segment of an image
+// specifier looks like a registry host with a port, e.g.,
+// `localhost:5000`, rather than an image name with a tag.
+func isImagePortSegment(segment string) bool {
+	i := strings.LastIndex(segment, ":")
+	if i < 0 || i == len(segment)-1 {
+		return false
+	}
+	for _, c := range segment[i+1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseImageSpecifier splits a specifier of the form
+// `oci://<image>:<tag>/<path>` or `oci://<image>@<digest>/<path>`
+// into the image reference and the path of the module within the
+// image. The last return value is false if the specifier is not an
+// image specifier, or does not include a tag or digest.
+func parseImageSpecifier(specifier string) (string, string, bool) {
+	if !strings.HasPrefix(specifier, imageScheme) {
+		return "", "", false
+	}
+	segments := strings.Split(specifier[len(imageScheme):], "/")
+	for i, segment := range segments {
+		// the first segment could be a registry host with a port,
+		// if there are more segments to come
+		if i == 0 && len(segments) > 1 && isImagePortSegment(segment) {
+			continue
+		}
+		if strings.ContainsAny(segment, ":@") {
+			ref := strings.Join(segments[:i+1], "/")
+			return ref, strings.Join(segments[i+1:], "/"), true
+		}
+	}
+	return "", "", false
+}
+
+// imageFileSystem returns the modules filesystem for the image ref
+// given, fetching the image if necessary.
+func (i *ImageImporter) imageFileSystem(ref string) (vfs.FileSystem, error) {
+	if fs, ok := i.images[ref]; ok {
+		return fs, nil
+	}
+	imgVfs, err := i.source.EnsureImage(ref)
+	if err != nil {
+		return nil, err
+	}
+	fs := vfs.Chroot(imgVfs, image.ModulesDir)
+	i.images[ref] = fs
+	return fs, nil
+}
+
+// fromImage returns true if the location given is within one of the
+// images this importer has loaded modules from.
+func (i *ImageImporter) fromImage(loc vfs.Location) bool {
+	for _, fs := range i.images {
+		if loc.Vfs == fs {
+			return true
+		}
+	}
+	return false
+}
+
+// Import implements Importer.
+func (i *ImageImporter) Import(base vfs.Location, specifier, referrer string) ([]byte, vfs.Location, []Candidate) {
+	if isRelative(specifier) {
+		return nil, vfs.Nowhere, nil
+	}
+
+	var fs vfs.FileSystem
+	var modulePath, rule string
+
+	switch {
+	case strings.HasPrefix(specifier, imageScheme):
+		ref, p, ok := parseImageSpecifier(specifier)
+		if !ok {
+			return nil, vfs.Nowhere, []Candidate{{specifier, "image specifier must give a tag or digest"}}
+		}
+		imgFs, err := i.imageFileSystem(ref)
+		if err != nil {
+			trace(i, "unable to fetch image %q: %s", ref, err.Error())
+			return nil, vfs.Nowhere, []Candidate{{ref, "image could not be fetched: " + err.Error()}}
+		}
+		fs, modulePath, rule = imgFs, p, imageRulePrefix+ref
+	case i.fromImage(base):
+		// A bare import from a module within an image is looked for
+		// in the same image.
+		fs, modulePath, rule = base.Vfs, specifier, "via importing image"
+	default:
+		return nil, vfs.Nowhere, nil
+	}
+
+	bytes, loc, candidates := resolvePath(fs, modulePath)
+	for i := range candidates {
+		candidates[i].Path = fs.QualifyPath(candidates[i].Path)
+	}
+	qualifyCandidates(candidates, rule)
+	return bytes, loc, candidates
+}
@@ -0,0 +1,72 @@
+package resolve
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+func TestParseImageSpecifier(t *testing.T) {
+	tests := []struct {
+		specifier string
+
+		valid      bool
+		image, mod string
+	}{
+		{"oci://jkcfg/kubernetes:0.6.0/api", true, "jkcfg/kubernetes:0.6.0", "api"},
+		{"oci://jkcfg/kubernetes:0.6.0", true, "jkcfg/kubernetes:0.6.0", ""},
+		{"oci://lib:v1/deep/path", true, "lib:v1", "deep/path"},
+		{"oci://localhost:5000/lib:v1/mod", true, "localhost:5000/lib:v1", "mod"},
+		{"oci://registry.example.com/org/lib@sha256:abcdef/mod", true, "registry.example.com/org/lib@sha256:abcdef", "mod"},
+		{"oci://localhost:5000/lib/mod", false, "", ""},
+		{"oci://jkcfg/kubernetes", false, "", ""},
+		{"jkcfg/kubernetes:0.6.0/api", false, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.specifier, func(t *testing.T) {
+			image, mod, ok := parseImageSpecifier(test.specifier)
+			assert.Equal(t, test.valid, ok)
+			assert.Equal(t, test.image, image)
+			assert.Equal(t, test.mod, mod)
+		})
+	}
+}
+
+type testImageSource map[string]string
+
+func (s testImageSource) EnsureImage(image string) (vfs.FileSystem, error) {
+	if dir, ok := s[image]; ok {
+		return ScriptBase(dir).Vfs, nil
+	}
+	return nil, fmt.Errorf("image %s not found", image)
+}
+
+func TestImageImporter(t *testing.T) {
+	importer := NewImageImporter(testImageSource{
+		"lib:v1": "testfiles/image",
+	})
+
+	// Resolving a module in an image
+	bytes, loc, _ := importer.Import(ScriptBase("testfiles"), "oci://lib:v1/lib", "test.js")
+	assert.NotNil(t, bytes)
+	assert.Equal(t, "lib/index.js", loc.Path)
+
+	// .. from which modules in the same image can be imported
+	bytes, loc, _ = importer.Import(vfs.Location{Vfs: loc.Vfs, Path: "lib"}, "lib/helper", "lib/index.js")
+	assert.NotNil(t, bytes)
+	assert.Equal(t, "lib/helper.js", loc.Path)
+
+	// .. but bare imports from elsewhere are not resolved
+	bytes, loc, _ = importer.Import(ScriptBase("testfiles"), "lib/helper", "test.js")
+	assert.Nil(t, bytes)
+	assert.Equal(t, vfs.Nowhere, loc)
+
+	// An image that can't be fetched
+	bytes, _, candidates := importer.Import(ScriptBase("testfiles"), "oci://missing:v1/lib", "test.js")
+	assert.Nil(t, bytes)
+	assert.Len(t, candidates, 1)
+}
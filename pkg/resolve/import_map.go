@@ -0,0 +1,202 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+/* ## Import maps
+
+An import map rewrites the specifiers in import statements before
+they are resolved by the importers. It follows the format of the WICG
+import maps proposal (https://github.com/WICG/import-maps):
+
+    {
+      "imports": {
+        "@corp/k8s": "./vendor/k8s/index.js",
+        "@corp/k8s/": "./vendor/k8s/",
+        "lodash": "oci://registry.example.com/lodash:4.17.15/lodash"
+      },
+      "scopes": {
+        "./legacy/": {
+          "@corp/k8s": "@corp/k8s-v1"
+        }
+      }
+    }
+
+Keys in "imports" are bare specifiers; a key ending in `/` maps any
+specifier it is a prefix of, by replacing the prefix. Targets that
+are paths (i.e., start with `/`, `./` or `../`) are resolved relative
+to the directory containing the import map, and loaded from the host
+filesystem; any other target is itself resolved using the importers,
+as though it were the specifier given in the import statement.
+
+The mappings under "scopes" apply only to imports from modules whose
+path starts with the scope (or, if the scope does not end with `/`,
+is exactly the scope). Scopes are also resolved relative to the
+directory containing the import map. The most specific scope that
+maps a specifier is used, falling back to the top-level "imports".
+
+Relative specifiers are never rewritten.
+*/
+
+type specifierMap map[string]string
+
+type scope struct {
+	prefix  string
+	imports specifierMap
+}
+
+// ImportMap rewrites import specifiers according to the rules given
+// above.
+type ImportMap struct {
+	host    vfs.FileSystem
+	imports specifierMap
+	scopes  []scope
+}
+
+var hostFileSystem = vfs.User("/", http.Dir("/"))
+
+// LoadImportMap reads an import map from the file given.
+func LoadImportMap(file string) (*ImportMap, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m, err := ParseImportMap(data, filepath.Dir(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return m, nil
+}
+
+// ParseImportMap parses an import map in JSON format. Paths in the
+// import map are resolved relative to the (absolute) directory given.
+func ParseImportMap(data []byte, dir string) (*ImportMap, error) {
+	var raw struct {
+		Imports map[string]string            `json:"imports"`
+		Scopes  map[string]map[string]string `json:"scopes"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	m := &ImportMap{host: hostFileSystem}
+	var err error
+	if m.imports, err = normaliseSpecifierMap(raw.Imports, dir); err != nil {
+		return nil, err
+	}
+	for prefix, imports := range raw.Scopes {
+		s := scope{prefix: resolveMapPath(dir, prefix)}
+		if s.imports, err = normaliseSpecifierMap(imports, dir); err != nil {
+			return nil, fmt.Errorf("scope %q: %v", prefix, err)
+		}
+		m.scopes = append(m.scopes, s)
+	}
+	// Most specific scope first
+	sort.Slice(m.scopes, func(i, j int) bool {
+		return len(m.scopes[i].prefix) > len(m.scopes[j].prefix)
+	})
+	return m, nil
+}
+
+func isPathTarget(t string) bool {
+	return path.IsAbs(t) || isRelative(t)
+}
+
+// resolveMapPath makes a path given in an import map absolute,
+// keeping any trailing slash (which is significant).
+func resolveMapPath(dir, p string) string {
+	if !isPathTarget(p) {
+		return p
+	}
+	resolved := p
+	if !path.IsAbs(p) {
+		resolved = path.Join(filepath.ToSlash(dir), p)
+	}
+	if strings.HasSuffix(p, "/") && !strings.HasSuffix(resolved, "/") {
+		resolved += "/"
+	}
+	return resolved
+}
+
+func normaliseSpecifierMap(raw map[string]string, dir string) (specifierMap, error) {
+	m := specifierMap{}
+	for specifier, target := range raw {
+		if specifier == "" || isPathTarget(specifier) {
+			return nil, fmt.Errorf("only bare specifiers can be mapped, got %q", specifier)
+		}
+		if strings.HasSuffix(specifier, "/") && !strings.HasSuffix(target, "/") {
+			return nil, fmt.Errorf("target %q for prefix %q must also end with '/'", target, specifier)
+		}
+		m[specifier] = resolveMapPath(dir, target)
+	}
+	return m, nil
+}
+
+// lookup finds the target for a specifier, either as an exact match
+// or by the longest matching prefix.
+func (m specifierMap) lookup(specifier string) (string, bool) {
+	if target, ok := m[specifier]; ok {
+		return target, true
+	}
+	var longest string
+	for prefix := range m {
+		if strings.HasSuffix(prefix, "/") && strings.HasPrefix(specifier, prefix) && len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+	if longest == "" {
+		return "", false
+	}
+	return m[longest] + specifier[len(longest):], true
+}
+
+func (s scope) matches(referrer string) bool {
+	if strings.HasSuffix(s.prefix, "/") {
+		return strings.HasPrefix(referrer, s.prefix)
+	}
+	return referrer == s.prefix
+}
+
+// Map rewrites a specifier, given the path of the importing module. If
+// the specifier is mapped, the rewritten specifier is returned along
+// with the base location against which it should be resolved; a base
+// of vfs.Nowhere means the base of the importing module should be
+// used.
+func (m *ImportMap) Map(specifier, referrer string) (string, vfs.Location, bool) {
+	if isRelative(specifier) {
+		return "", vfs.Nowhere, false
+	}
+	target, ok := "", false
+	for _, s := range m.scopes {
+		if s.matches(referrer) {
+			if target, ok = s.imports.lookup(specifier); ok {
+				break
+			}
+		}
+	}
+	if !ok {
+		if target, ok = m.imports.lookup(specifier); !ok {
+			return "", vfs.Nowhere, false
+		}
+	}
+	if path.IsAbs(target) {
+		// Path targets are loaded from the host filesystem, as a
+		// relative import from its root.
+		return "." + target, vfs.Location{Vfs: m.host, Path: "/"}, true
+	}
+	return target, vfs.Nowhere, true
+}
@@ -0,0 +1,73 @@
+package resolve
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+const testImportMap = `{
+  "imports": {
+    "@corp/k8s": "./vendor/k8s/index.js",
+    "@corp/k8s/": "./vendor/k8s/",
+    "@corp/k8s/deep/": "/elsewhere/deep/",
+    "lodash": "oci://example.com/lodash:v1/lodash"
+  },
+  "scopes": {
+    "./legacy/": {
+      "@corp/k8s": "@corp/k8s-v1"
+    },
+    "./legacy/special.js": {
+      "@corp/k8s": "./special/k8s.js"
+    }
+  }
+}`
+
+func TestImportMap(t *testing.T) {
+	m, err := ParseImportMap([]byte(testImportMap), "/project")
+	assert.NoError(t, err)
+
+	host := vfs.Location{Vfs: hostFileSystem, Path: "/"}
+
+	tests := []struct {
+		name                string
+		specifier, referrer string
+
+		mapped bool
+		target string
+		base   vfs.Location
+	}{
+		{"exact", "@corp/k8s", "/project/index.js", true, "./project/vendor/k8s/index.js", host},
+		{"prefix", "@corp/k8s/api/v1", "/project/index.js", true, "./project/vendor/k8s/api/v1", host},
+		{"longest prefix", "@corp/k8s/deep/mod", "/project/index.js", true, "./elsewhere/deep/mod", host},
+		{"bare target", "lodash", "/project/index.js", true, "oci://example.com/lodash:v1/lodash", vfs.Nowhere},
+		{"not mapped", "other", "/project/index.js", false, "", vfs.Nowhere},
+		{"relative", "./vendor/k8s", "/project/index.js", false, "", vfs.Nowhere},
+		{"scope", "@corp/k8s", "/project/legacy/index.js", true, "@corp/k8s-v1", vfs.Nowhere},
+		{"scope falls back", "@corp/k8s/api", "/project/legacy/index.js", true, "./project/vendor/k8s/api", host},
+		{"exact scope", "@corp/k8s", "/project/legacy/special.js", true, "./project/special/k8s.js", host},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target, base, ok := m.Map(test.specifier, test.referrer)
+			assert.Equal(t, test.mapped, ok)
+			assert.Equal(t, test.target, target)
+			assert.Equal(t, test.base, base)
+		})
+	}
+}
+
+func TestImportMapInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		`{"imports": {"./relative": "foo"}}`,
+		`{"imports": {"prefix/": "not-a-prefix"}}`,
+		`{"scopes": {"./scope/": {"prefix/": "not-a-prefix"}}}`,
+		`not JSON`,
+	} {
+		_, err := ParseImportMap([]byte(invalid), "/project")
+		assert.Error(t, err, invalid)
+	}
+}
@@ -2,7 +2,6 @@ package resolve
 
 import (
 	"encoding/json"
-	"log"
 	"os"
 	"path"
 	"strings"
@@ -45,11 +44,15 @@ The algorithm for resolving a module path is described in
 adapts that algorithm to account for only supporting ES2015 modules,
 by
 
- - using the file extensions `.js`, `.mjs`
+ - using the file extensions `.mjs`, `.js`, `.ts`
  - using the field `module` in `package.json`, instead of `main`
  - not looking for node_modules directories above the directory given
    as the top-level.
 
+Packages that have the field `exports` (or `imports`) in
+`package.json` are resolved according to the rules in
+npm_exports.go.
+
 The "standard" rules for resolving a path to a file are delegated to
 resolveFile and resolveIndex; but, since NPM resolution also has to
 account for package.json files, they are not used in the same way as
@@ -72,12 +75,21 @@ func NewNodeImporter(vfs vfs.FileSystem) *NodeImporter {
 // Import is the entry point into the module resolution algorithm.
 func (n *NodeImporter) Import(base vfs.Location, specifier, referrer string) ([]byte, vfs.Location, []Candidate) {
 	if path.IsAbs(specifier) {
-		log.Fatalf("absolute import path %q not allowed in %q", specifier, referrer)
+		return nil, vfs.Nowhere, []Candidate{{specifier, "absolute import path not allowed"}}
 	}
 	if isRelative(specifier) {
 		return nil, vfs.Nowhere, nil
 	}
-	bytes, loc, candidates := n.loadAsModule(specifier, base.Path)
+	var (
+		bytes      []byte
+		loc        vfs.Location
+		candidates []Candidate
+	)
+	if strings.HasPrefix(specifier, "#") {
+		bytes, loc, candidates = n.loadImport(specifier, base.Path)
+	} else {
+		bytes, loc, candidates = n.loadAsModule(specifier, base.Path)
+	}
 	for i := range candidates {
 		candidates[i].Path = n.vfs.QualifyPath(candidates[i].Path)
 	}
@@ -99,7 +111,7 @@ func (n *NodeImporter) loadAsPath(path string) ([]byte, vfs.Location, []Candidat
 		// the path as-is as a candidate
 		return nil, vfs.Nowhere, fileCandidates
 	case err != nil:
-		log.Fatal(err)
+		return nil, vfs.Nowhere, append(fileCandidates, Candidate{path, "could not stat path: " + err.Error()})
 
 	case info.IsDir():
 		bytes, loc, dirCandidates := n.loadAsDir(path)
@@ -145,12 +157,21 @@ func (n *NodeImporter) loadAsModule(specifier, base string) (_ []byte, _ vfs.Loc
 		qualifyCandidates(candidates, "via NPM resolution")
 	}()
 
+	pkgName, subpath := splitPackageSpecifier(specifier)
 	bits := strings.Split(base, "/")
 	for i := len(bits); i >= 0; i-- {
 		if i > 0 && bits[i-1] == "node_modules" {
 			continue
 		}
-		path := path.Join(append(bits[:i], "node_modules", specifier)...)
+		modulesDir := path.Join(append(bits[:i:i], "node_modules")...)
+		// If the package declares its exports, those are the only
+		// modules that can be imported from it.
+		pkgDir := path.Join(modulesDir, pkgName)
+		if pkg := readPackageJSON(n.vfs, pkgDir); pkg != nil && jsonKind(pkg.Exports) != 'n' {
+			bytes, loc, exportCandidates := n.loadExports(pkgDir, pkg.Exports, subpath)
+			return bytes, loc, append(candidates, exportCandidates...)
+		}
+		path := path.Join(modulesDir, specifier)
 		bytes, loc, pathCandidates := n.loadAsPath(path)
 		candidates = append(candidates, pathCandidates...)
 		if bytes != nil {
@@ -0,0 +1,297 @@
+package resolve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/shurcooL/httpfs/vfsutil"
+
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+/* ## Package exports and imports
+
+Packages can declare the modules they make available using the field
+`exports` in `package.json`, in which case _only_ those modules can be
+imported from the package. Similarly, the field `imports` declares
+aliases, starting with `#`, that can be used by modules within the
+package. The rules are described in
+<https://nodejs.org/api/packages.html#packages_package_entry_points>;
+in summary:
+
+ - `exports` is either a target for the package itself (i.e., for
+   the subpath `.`), or an object with subpaths (starting with `./`)
+   as keys.
+
+ - a subpath key may contain a single `*`, which matches anything,
+   and is substituted into the target; or, it may end in `/`, in
+   which case it matches any subpath it is a prefix of.
+
+ - a target is a path relative to the package directory (starting
+   with `./`), an array of targets, which are tried in order, or an
+   object with conditions as keys. The first condition (in the order
+   given in package.json) that jk satisfies is used; jk satisfies
+   `jk`, `import`, and `default`.
+
+ - `imports` has the same form as `exports`, except that the keys
+   start with `#`, and targets may also be bare specifiers (which are
+   resolved as though imported from the package).
+
+Unlike other resolution, the targets are not subject to guessing file
+extensions or index files.
+*/
+
+// exportConditions are the conditions jk satisfies, in a package's
+// `exports` or `imports`.
+var exportConditions = []string{"jk", "import", "default"}
+
+func isExportCondition(c string) bool {
+	for _, cond := range exportConditions {
+		if c == cond {
+			return true
+		}
+	}
+	return false
+}
+
+type packageJSON struct {
+	Module  string          `json:"module"`
+	Exports json.RawMessage `json:"exports"`
+	Imports json.RawMessage `json:"imports"`
+}
+
+// readPackageJSON reads the package.json file in the directory given,
+// returning nil if there isn't one that can be parsed.
+func readPackageJSON(fs vfs.FileSystem, dir string) *packageJSON {
+	data, err := vfsutil.ReadFile(fs, path.Join(dir, "package.json"))
+	if err != nil {
+		return nil
+	}
+	var pkg packageJSON
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil
+	}
+	return &pkg
+}
+
+// splitPackageSpecifier splits a bare specifier into the package
+// name, and the subpath within the package (which is `.` for the
+// package itself).
+func splitPackageSpecifier(specifier string) (string, string) {
+	parts := strings.SplitN(specifier, "/", 3)
+	n := 1
+	if strings.HasPrefix(specifier, "@") && len(parts) > 1 {
+		n = 2
+	}
+	if len(parts) <= n {
+		return specifier, "."
+	}
+	return strings.Join(parts[:n], "/"), "./" + strings.Join(parts[n:], "/")
+}
+
+type jsonEntry struct {
+	key   string
+	value json.RawMessage
+}
+
+// objectEntries decodes a JSON object into its entries, keeping the
+// order in which they appear (which matters for conditions). It
+// returns false if the value is not an object.
+func objectEntries(raw json.RawMessage) ([]jsonEntry, bool) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	var entries []jsonEntry
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, false
+		}
+		entries = append(entries, jsonEntry{key, value})
+	}
+	return entries, true
+}
+
+func jsonKind(raw json.RawMessage) byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return 'n'
+	}
+	return raw[0]
+}
+
+// matchSubpath finds the entry in a subpath map (from `exports` or
+// `imports`) for the subpath given, returning the entry and the part
+// of the subpath matched by a `*` or trailing `/`, if any.
+func matchSubpath(entries []jsonEntry, subpath string) (jsonEntry, string, bool) {
+	for _, e := range entries {
+		if e.key == subpath && !strings.Contains(e.key, "*") {
+			return e, "", true
+		}
+	}
+
+	var best jsonEntry
+	var bestPrefix, bestMatch string
+	found := false
+	for _, e := range entries {
+		var prefix, suffix string
+		if i := strings.Index(e.key, "*"); i >= 0 {
+			prefix, suffix = e.key[:i], e.key[i+1:]
+		} else if strings.HasSuffix(e.key, "/") {
+			prefix = e.key
+		} else {
+			continue
+		}
+		if len(subpath) < len(prefix)+len(suffix) ||
+			!strings.HasPrefix(subpath, prefix) || !strings.HasSuffix(subpath, suffix) {
+			continue
+		}
+		if !found || len(prefix) > len(bestPrefix) {
+			best, bestPrefix, found = e, prefix, true
+			bestMatch = subpath[len(prefix) : len(subpath)-len(suffix)]
+		}
+	}
+	return best, bestMatch, found
+}
+
+// resolveExportTarget resolves a target from `exports` or `imports`,
+// given the directory of the package and the part of the subpath
+// matched by a pattern. The rule is the explanation so far, to use
+// for candidates.
+func (n *NodeImporter) resolveExportTarget(pkgDir string, target json.RawMessage, match, rule string, allowBare bool) ([]byte, vfs.Location, []Candidate) {
+	switch jsonKind(target) {
+	case '"':
+		var t string
+		if err := json.Unmarshal(target, &t); err != nil {
+			return nil, vfs.Nowhere, nil
+		}
+		if strings.Contains(t, "*") {
+			t = strings.Replace(t, "*", match, -1)
+		} else if strings.HasSuffix(t, "/") {
+			t += match
+		}
+		if !strings.HasPrefix(t, "./") {
+			if allowBare && !isRelative(t) && !path.IsAbs(t) {
+				bytes, loc, candidates := n.loadAsModule(t, pkgDir)
+				qualifyCandidates(candidates, rule)
+				return bytes, loc, candidates
+			}
+			return nil, vfs.Nowhere, []Candidate{{t, "invalid target (must start with ./), " + rule}}
+		}
+		p := path.Join(pkgDir, t)
+		if p != pkgDir && !strings.HasPrefix(p, strings.TrimSuffix(pkgDir, "/")+"/") {
+			return nil, vfs.Nowhere, []Candidate{{t, "invalid target (outside package), " + rule}}
+		}
+		candidates := []Candidate{{p, verbatimRule + ", " + rule}}
+		bytes, err := vfsutil.ReadFile(n.vfs, p)
+		if err != nil {
+			return nil, vfs.Nowhere, candidates
+		}
+		return bytes, vfs.Location{Vfs: n.vfs, Path: p}, candidates
+
+	case '[':
+		var targets []json.RawMessage
+		if err := json.Unmarshal(target, &targets); err != nil {
+			return nil, vfs.Nowhere, nil
+		}
+		var candidates []Candidate
+		for i, t := range targets {
+			bytes, loc, tCandidates := n.resolveExportTarget(pkgDir, t, match, fmt.Sprintf("%s[%d]", rule, i), allowBare)
+			candidates = append(candidates, tCandidates...)
+			if bytes != nil {
+				return bytes, loc, candidates
+			}
+		}
+		return nil, vfs.Nowhere, candidates
+
+	case '{':
+		entries, _ := objectEntries(target)
+		var candidates []Candidate
+		for _, e := range entries {
+			if !isExportCondition(e.key) {
+				continue
+			}
+			bytes, loc, cCandidates := n.resolveExportTarget(pkgDir, e.value, match, rule+"."+e.key, allowBare)
+			candidates = append(candidates, cCandidates...)
+			if bytes != nil {
+				return bytes, loc, candidates
+			}
+		}
+		return nil, vfs.Nowhere, candidates
+	}
+
+	// null (or something we don't understand) means the subpath is
+	// not available
+	return nil, vfs.Nowhere, nil
+}
+
+// isSubpathMap returns true if the exports value given has subpaths
+// as keys (rather than conditions).
+func isSubpathMap(entries []jsonEntry) bool {
+	for _, e := range entries {
+		if strings.HasPrefix(e.key, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// loadExports resolves a subpath of a package using the package's
+// `exports` field.
+func (n *NodeImporter) loadExports(pkgDir string, exports json.RawMessage, subpath string) ([]byte, vfs.Location, []Candidate) {
+	packageJSONPath := path.Join(pkgDir, "package.json")
+	entries, isObject := objectEntries(exports)
+
+	if !isObject || !isSubpathMap(entries) {
+		// The exports value is the target for the package itself
+		if subpath != "." {
+			return nil, vfs.Nowhere, []Candidate{{path.Join(pkgDir, subpath), "not exported by " + packageJSONPath}}
+		}
+		return n.resolveExportTarget(pkgDir, exports, "", "via .exports in "+packageJSONPath, false)
+	}
+
+	e, match, ok := matchSubpath(entries, subpath)
+	if !ok {
+		return nil, vfs.Nowhere, []Candidate{{path.Join(pkgDir, subpath), "not exported by " + packageJSONPath}}
+	}
+	rule := fmt.Sprintf("via .exports[%q] in %s", e.key, packageJSONPath)
+	bytes, loc, candidates := n.resolveExportTarget(pkgDir, e.value, match, rule, false)
+	if bytes == nil && len(candidates) == 0 {
+		// e.g., the target was null, or had no conditions we satisfy
+		candidates = []Candidate{{path.Join(pkgDir, subpath), "not exported, " + rule}}
+	}
+	return bytes, loc, candidates
+}
+
+// loadImport resolves a specifier starting with `#` using the
+// `imports` field of the package.json nearest to the importing module
+// (as given by base).
+func (n *NodeImporter) loadImport(specifier, base string) ([]byte, vfs.Location, []Candidate) {
+	dir := path.Clean(base)
+	for {
+		if pkg := readPackageJSON(n.vfs, dir); pkg != nil {
+			packageJSONPath := path.Join(dir, "package.json")
+			entries, _ := objectEntries(pkg.Imports)
+			e, match, ok := matchSubpath(entries, specifier)
+			if !ok {
+				return nil, vfs.Nowhere, []Candidate{{specifier, "not in .imports in " + packageJSONPath}}
+			}
+			rule := fmt.Sprintf("via .imports[%q] in %s", e.key, packageJSONPath)
+			return n.resolveExportTarget(dir, e.value, match, rule, true)
+		}
+		parent := path.Dir(dir)
+		if parent == dir {
+			return nil, vfs.Nowhere, []Candidate{{specifier, "no package.json with .imports"}}
+		}
+		dir = parent
+	}
+}
@@ -3,6 +3,10 @@ package resolve
 import (
 	"os"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/vfs"
 )
 
 func TestNodeModuleImport(t *testing.T) {
@@ -68,3 +72,60 @@ func TestNodeModuleFail(t *testing.T) {
 		t.Errorf("Expected failure to resolve modfoo, but found at %s", loc.Path)
 	}
 }
+
+func TestNodeModuleAbsolute(t *testing.T) {
+	// An absolute path is not resolved, and says why
+	node := NewNodeImporter(ScriptBase("testfiles").Vfs)
+	bytes, loc, candidates := node.Import(ScriptBase("testfiles"), "/modfoo", "stdin")
+	assert.Nil(t, bytes)
+	assert.Equal(t, vfs.Nowhere, loc)
+	assert.Equal(t, []Candidate{{"/modfoo", "absolute import path not allowed"}}, candidates)
+}
+
+func TestNodeModuleExports(t *testing.T) {
+	node := NewNodeImporter(ScriptBase("testfiles").Vfs)
+
+	tests := []struct {
+		name, base, specifier string
+		expected              string // the path resolved to, or empty if it should fail
+	}{
+		{"package, first satisfied condition", "/", "modexports", "node_modules/modexports/esm/index.js"},
+		{"subpath pattern", "/", "modexports/features/alpha", "node_modules/modexports/esm/features/alpha.js"},
+		{"fallback array", "/", "modexports/util", "node_modules/modexports/esm/util.js"},
+		{"null target", "/", "modexports/internal/secret", ""},
+		{"not exported", "/", "modexports/esm/hidden.js", ""},
+		{"no extension guessing", "/", "modexports/features/alpha.js", ""},
+		{"exports sugar", "/", "modsugar", "node_modules/modsugar/main.js"},
+		{"exports sugar subpath", "/", "modsugar/main.js", ""},
+		{"scoped package", "/", "@scope/pkg/sub", "node_modules/@scope/pkg/lib/sub.js"},
+		{"imports, bare target", "node_modules/modexports/esm", "#dep", "node_modules/modfoo/index.js"},
+		{"imports, pattern", "node_modules/modexports/esm", "#helpers/format", "node_modules/modexports/esm/helpers/format.js"},
+		{"imports, not declared", "node_modules/modexports/esm", "#other", ""},
+		{"imports, outside package", "node_modules", "#dep", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			base := vfs.Location{Vfs: node.vfs, Path: test.base}
+			bytes, loc, candidates := node.Import(base, test.specifier, "stdin")
+			if test.expected == "" {
+				assert.Nil(t, bytes)
+				assert.NotEmpty(t, candidates)
+				return
+			}
+			if assert.NotNil(t, bytes, "did not resolve %s", test.specifier) {
+				assert.Equal(t, test.expected, loc.Path)
+			}
+		})
+	}
+}
+
+func TestNodeModuleExportsCandidates(t *testing.T) {
+	node := NewNodeImporter(ScriptBase("testfiles").Vfs)
+	_, _, candidates := node.Import(ScriptBase("/"), "modexports/util", "stdin")
+	if assert.Len(t, candidates, 2) {
+		assert.Contains(t, candidates[0].Path, "esm/missing.js")
+		assert.Contains(t, candidates[0].Rule, `via .exports["./util"] in node_modules/modexports/package.json`)
+		assert.Contains(t, candidates[1].Path, "esm/util.js")
+	}
+}
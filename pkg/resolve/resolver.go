@@ -2,6 +2,7 @@ package resolve
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -9,7 +10,10 @@ import (
 	"reflect"
 	"strings"
 
+	"github.com/shurcooL/httpfs/vfsutil"
+
 	"github.com/jkcfg/jk/pkg/record"
+	"github.com/jkcfg/jk/pkg/stacktrace"
 	"github.com/jkcfg/jk/pkg/vfs"
 )
 
@@ -56,10 +60,15 @@ func ScriptBase(path string) vfs.Location {
 // Resolver implements module resolution by deferring to the set of
 // importers that it's given.
 type Resolver struct {
-	recorder  *record.Recorder
-	loader    Loader
-	base      vfs.Location
-	importers []Importer
+	recorder    *record.Recorder
+	loader      Loader
+	base        vfs.Location
+	importMap   *ImportMap
+	importers   []Importer
+	translators map[string]Translator
+	sources     *stacktrace.Sources
+	graph       *Graph
+	stderr      io.Writer
 }
 
 // SetRecorder instructs Resolver to record actions in the specified recoder.
@@ -68,15 +77,81 @@ func (r *Resolver) SetRecorder(recorder *record.Recorder) {
 	r.recorder = recorder
 }
 
-// NewResolver creates a new Resolver.
+// SetImportMap instructs Resolver to rewrite specifiers using the
+// import map given, before consulting the importers. Call with nil to
+// disable.
+func (r *Resolver) SetImportMap(m *ImportMap) {
+	r.importMap = m
+}
+
+// SetGraph instructs Resolver to add each import it resolves to the
+// Graph given. Call with nil to disable.
+func (r *Resolver) SetGraph(g *Graph) {
+	r.graph = g
+}
+
+// SetSources instructs Resolver to record the code of the modules it
+// loads in the Sources given, so that errors can be reported in terms
+// of their original source. Call with nil to disable.
+func (r *Resolver) SetSources(sources *stacktrace.Sources) {
+	r.sources = sources
+}
+
+// SetStderr instructs Resolver to report modules that can't be
+// imported to the writer given, rather than os.Stderr. Call with nil
+// to report to os.Stderr.
+func (r *Resolver) SetStderr(w io.Writer) {
+	r.stderr = w
+}
+
+// SetTranslator instructs Resolver to translate modules with the
+// file extension given (e.g., ".ts") using the Translator given,
+// before loading them. Call with nil to remove the translator for an
+// extension.
+func (r *Resolver) SetTranslator(ext string, t Translator) {
+	if t == nil {
+		delete(r.translators, ext)
+		return
+	}
+	r.translators[ext] = t
+}
+
+// NewResolver creates a new Resolver. TypeScript modules are
+// translated (without caching) unless another translator is set, and
+// JSON and YAML files are translated into modules exporting their
+// value.
 func NewResolver(loader Loader, base vfs.Location, importers ...Importer) *Resolver {
 	return &Resolver{
 		loader:    loader,
 		base:      base,
 		importers: importers,
+		translators: map[string]Translator{
+			".ts":   &TypeScriptTranslator{},
+			".json": JSONTranslator{},
+			".yaml": YAMLTranslator{},
+			".yml":  YAMLTranslator{},
+		},
 	}
 }
 
+// Translate applies the translator for the file extension of the
+// location given, if there is one, to the module code given.
+func (r *Resolver) Translate(loc vfs.Location, data []byte) ([]byte, error) {
+	translator, ok := r.translators[path.Ext(loc.Path)]
+	if !ok {
+		return data, nil
+	}
+	return translator.Translate(loc, data)
+}
+
+func (r Resolver) errorf(f string, args ...interface{}) {
+	w := r.stderr
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, f, args...)
+}
+
 func importerName(i Importer) string {
 	return strings.TrimSuffix(reflect.ValueOf(i).Elem().Type().String()[8:], "Importer")
 }
@@ -92,16 +167,34 @@ func trace(i Importer, f string, args ...interface{}) {
 // ResolveModule imports the specifier from an import statement located in the
 // referrer module.
 func (r Resolver) ResolveModule(specifier, referrer string) (string, int) {
+	// If there's an import map, it gets first go at the specifier;
+	// what it maps to is then resolved by the importers as usual. The
+	// standard library's own imports are left alone.
+	base, importSpecifier := r.base, specifier
+	if r.importMap != nil && !IsStdModule(referrer) {
+		referrerPath := path.Join(r.base.CanonicalPath(), path.Base(referrer))
+		if mapped, mappedBase, ok := r.importMap.Map(specifier, referrerPath); ok {
+			if debugImports {
+				log.Printf("debug: import map: %s -> %s", specifier, mapped)
+			}
+			importSpecifier = mapped
+			if mappedBase != vfs.Nowhere {
+				base = mappedBase
+			}
+		}
+	}
+
 	// The first importer that resolves the specifier wins.
 	var resolved vfs.Location
+	var resolvedBy Importer
 	var source string
 	var candidates []Candidate
 
 	for _, importer := range r.importers {
-		data, loc, considered := importer.Import(r.base, specifier, referrer)
+		data, loc, considered := importer.Import(base, importSpecifier, referrer)
 
 		if len(data) == 0 {
-			trace(importer, "✘ import %s from %s (base=%s)", specifier, referrer, r.base.CanonicalPath())
+			trace(importer, "✘ import %s from %s (base=%s)", importSpecifier, referrer, base.CanonicalPath())
 		} else {
 			fullpath := loc.CanonicalPath()
 			if r.recorder != nil && !loc.Vfs.IsInternal() {
@@ -110,35 +203,69 @@ func (r Resolver) ResolveModule(specifier, referrer string) (string, int) {
 					"path":      fullpath,
 				})
 			}
-			trace(importer, "✔ import %s from %s (base=%s) -> %s", specifier, referrer, r.base.CanonicalPath(), fullpath)
+			trace(importer, "✔ import %s from %s (base=%s) -> %s", importSpecifier, referrer, base.CanonicalPath(), fullpath)
 		}
 
 		candidates = append(candidates, considered...)
 		if data != nil {
 			source = string(data)
 			resolved = loc
+			resolvedBy = importer
 			break
 		}
 	}
 
 	if source == "" {
-		fmt.Fprintf(os.Stderr, "error: could not import '%s' from '%s'\n", specifier, path.Join(r.base.Path, referrer))
+		r.errorf("error: could not import '%s' from '%s'\n", specifier, path.Join(r.base.Path, referrer))
+		if importSpecifier != specifier {
+			r.errorf("(mapped to '%s' by the import map)\n", importSpecifier)
+		}
 		if len(candidates) > 0 {
-			fmt.Fprintf(os.Stderr, "candidates considered:\n")
+			r.errorf("candidates considered:\n")
 			for _, candidate := range candidates {
-				fmt.Fprintf(os.Stderr, "    %s (%s)\n", candidate.Path, candidate.Rule)
+				r.errorf("    %s (%s)\n", candidate.Path, candidate.Rule)
 			}
 		}
 		return "", 1
 	}
 
+	translated, err := r.Translate(resolved, []byte(source))
+	if err != nil {
+		r.errorf("error: could not import '%s' from '%s'\n", specifier, path.Join(r.base.Path, referrer))
+		r.errorf("%v\n", err)
+		return "", 1
+	}
+	source = string(translated)
+
 	nextResolver := r
 	nextResolver.base = vfs.Location{Vfs: resolved.Vfs, Path: path.Dir(resolved.Path)}
 	// TODO the path will be used to uniquify modules, so it needs to
 	// be uniquified itself, by the location, somehow
 	fullpath := resolved.CanonicalPath()
+	if r.graph != nil {
+		edge := Import{
+			Referrer:  referrer,
+			Specifier: specifier,
+			Resolved:  fullpath,
+			Importer:  importerName(resolvedBy),
+			Internal:  resolved.Vfs.IsInternal(),
+		}
+		if importSpecifier != specifier {
+			edge.Mapped = importSpecifier
+		}
+		r.graph.Add(edge)
+	}
+	if r.sources != nil {
+		r.sources.Add(fullpath, source, func(p string) ([]byte, error) {
+			return vfsutil.ReadFile(resolved.Vfs, path.Join(path.Dir(resolved.Path), p))
+		})
+	}
 	if err := r.loader.LoadModule(fullpath, source, nextResolver.ResolveModule); err != nil {
-		fmt.Fprintf(os.Stderr, "%v", err)
+		msg := err.Error()
+		if r.sources != nil {
+			msg = r.sources.FormatException(msg)
+		}
+		r.errorf("%s", msg)
 		return "", 1
 	}
 	return fullpath, 0
@@ -10,6 +10,10 @@ import (
 
 const (
 	stdPrefix = "@jkcfg/std"
+	// ResourceModule is the standard library module giving a module
+	// access to the files next to it. It's generated for each module
+	// importing it (see MagicImporter).
+	ResourceModule = "@jkcfg/std/resource"
 )
 
 // StdImporter is the standard library importer.
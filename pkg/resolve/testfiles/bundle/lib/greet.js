@@ -0,0 +1,5 @@
+import { prefix } from './prefix';
+
+export default function greet(name) {
+  return `${prefix}, ${name}`;
+}
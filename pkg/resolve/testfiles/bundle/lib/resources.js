@@ -0,0 +1,3 @@
+import { read } from '@jkcfg/std/resource';
+
+export const values = read('values.json');
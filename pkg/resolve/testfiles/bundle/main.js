@@ -0,0 +1,5 @@
+import * as std from '@jkcfg/std';
+import greet from './lib/greet';
+import values from './values.json';
+
+std.log(greet(values.name));
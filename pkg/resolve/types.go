@@ -25,3 +25,10 @@ type Importer interface {
 	// of the specifier attempted, including that returned.
 	Import(base vfs.Location, specifier, referrer string) (data []byte, resolved vfs.Location, candidates []Candidate)
 }
+
+// Translator is an object turning the contents of a file into ES 2015
+// module code, e.g., by transpiling it. Translators are selected by
+// the file extension of the resolved module.
+type Translator interface {
+	Translate(resolved vfs.Location, data []byte) ([]byte, error)
+}
@@ -0,0 +1,86 @@
+package resolve
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+
+	"github.com/jkcfg/jk/pkg/vfs"
+)
+
+// typeScriptCacheVersion is mixed into the cache key for transpiled
+// modules; change it when the transpiler or its options change, so
+// that stale output isn't used.
+const typeScriptCacheVersion = "esbuild-0.19.11/1"
+
+// TypeScriptTranslator is a Translator for TypeScript modules. Types
+// are stripped (there's no type checking), and the JavaScript output
+// has an inline source map, referring to the original file.
+//
+// If CacheDir is not empty, the output is cached there, keyed by a
+// hash of the module's path and contents.
+type TypeScriptTranslator struct {
+	CacheDir string
+}
+
+// Translate implements Translator.
+func (t *TypeScriptTranslator) Translate(resolved vfs.Location, data []byte) ([]byte, error) {
+	sourcefile := resolved.CanonicalPath()
+
+	var cachePath string
+	if t.CacheDir != "" {
+		hash := sha256.New()
+		hash.Write([]byte(typeScriptCacheVersion + "\x00" + sourcefile + "\x00"))
+		hash.Write(data)
+		cachePath = filepath.Join(t.CacheDir, hex.EncodeToString(hash.Sum(nil))+".js")
+		if out, err := ioutil.ReadFile(cachePath); err == nil {
+			return out, nil
+		}
+	}
+
+	result := api.Transform(string(data), api.TransformOptions{
+		Loader:     api.LoaderTS,
+		Target:     api.ESNext,
+		Sourcemap:  api.SourceMapInline,
+		Sourcefile: sourcefile,
+	})
+	if len(result.Errors) > 0 {
+		msgs := api.FormatMessages(result.Errors, api.FormatMessagesOptions{Kind: api.ErrorMessage})
+		return nil, errors.New(strings.TrimSpace(strings.Join(msgs, "")))
+	}
+
+	if cachePath != "" {
+		// Failing to cache the output is not fatal; it'll just have
+		// to be transpiled again next time.
+		writeCacheFile(cachePath, result.Code)
+	}
+	return result.Code, nil
+}
+
+// writeCacheFile writes a file atomically (by writing to a temporary
+// file and renaming it), so concurrent runs never see partial output.
+func writeCacheFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,70 @@
+package resolve
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTypeScript = `
+interface Greeting {
+  name: string;
+}
+
+export default function greet(g: Greeting): string {
+  return 'hello ' + g.name;
+}
+`
+
+func TestTypeScriptTranslate(t *testing.T) {
+	loc := ScriptBase("/project")
+	loc.Path = "greet.ts"
+
+	out, err := (&TypeScriptTranslator{}).Translate(loc, []byte(testTypeScript))
+	assert.NoError(t, err)
+	js := string(out)
+	assert.NotContains(t, js, "interface")
+	assert.NotContains(t, js, ": string")
+	assert.Contains(t, js, "export default function greet(g)")
+	assert.Contains(t, js, "//# sourceMappingURL=data:application/json;base64,")
+}
+
+func TestTypeScriptTranslateError(t *testing.T) {
+	loc := ScriptBase("/project")
+	loc.Path = "broken.ts"
+
+	_, err := (&TypeScriptTranslator{}).Translate(loc, []byte("export const x: = 1;"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "/project/broken.ts")
+	}
+}
+
+func TestTypeScriptCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jk-typescript")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	loc := ScriptBase("/project")
+	loc.Path = "greet.ts"
+	translator := &TypeScriptTranslator{CacheDir: dir}
+
+	out, err := translator.Translate(loc, []byte(testTypeScript))
+	assert.NoError(t, err)
+	cached, _ := filepath.Glob(filepath.Join(dir, "*.js"))
+	if assert.Len(t, cached, 1) {
+		// Doctor the cached output, to check it's what gets used
+		assert.NoError(t, ioutil.WriteFile(cached[0], []byte("export default 'cached';"), 0644))
+	}
+	out, err = translator.Translate(loc, []byte(testTypeScript))
+	assert.NoError(t, err)
+	assert.Equal(t, "export default 'cached';", string(out))
+
+	// Different content is a different cache entry
+	_, err = translator.Translate(loc, []byte(testTypeScript+"\nexport const x: number = 1;\n"))
+	assert.NoError(t, err)
+	cached, _ = filepath.Glob(filepath.Join(dir, "*.js"))
+	assert.Len(t, cached, 2)
+}
@@ -0,0 +1,260 @@
+// Package runcache implements a cache of the outputs of runs (e.g.,
+// of `jk generate`), so that a run can be skipped when none of its
+// inputs have changed.
+//
+// Since what a script imports and reads is only known after running
+// it, a cache entry is found in two steps. Each run has a key,
+// computed from what's known beforehand (the jk version, the script,
+// the command-line options and parameter values). The entry for a key
+// is a manifest listing each file that was used by the last run with
+// that key, along with a hash of its content, and each file written.
+// If all the files used are unchanged (and the environment variables
+// looked up, which are kept as hashes), the files written are restored
+// from the object store, where they are kept by the hash of their
+// content.
+package runcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jkcfg/jk/pkg/record"
+)
+
+// manifestVersion is included in keys, so that changes to the
+// manifest format invalidate existing entries.
+const manifestVersion = "1"
+
+// Cache is a run cache kept in a directory.
+type Cache struct {
+	dir string
+}
+
+// New creates a Cache using the directory given, which is created
+// when needed.
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Key computes a key for a run from the parts given, which should
+// include everything that can affect the run other than the files it
+// uses.
+func Key(parts ...string) string {
+	h := sha256.New()
+	io.WriteString(h, manifestVersion)
+	for _, part := range parts {
+		h.Write([]byte{0})
+		io.WriteString(h, part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type input struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+type output struct {
+	Path string      `json:"path"`
+	Hash string      `json:"hash"`
+	Mode os.FileMode `json:"mode"`
+}
+
+type envVar struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+type manifest struct {
+	Inputs  []input  `json:"inputs"`
+	Env     []envVar `json:"env,omitempty"`
+	Outputs []output `json:"outputs"`
+}
+
+func (c *Cache) manifestPath(key string) string {
+	return filepath.Join(c.dir, "manifests", key[:2], key+".json")
+}
+
+func (c *Cache) objectPath(hash string) string {
+	return filepath.Join(c.dir, "objects", hash[:2], hash)
+}
+
+// Store records the files used and written by a run with the key
+// given, as recorded by the recorder, and keeps a copy of each file
+// written. A run that executed commands or called plugins is not
+// stored, since what the commands or plugins used isn't known.
+func (c *Cache) Store(key string, recorder *record.Recorder) error {
+	if recorder.Has(record.Exec) || recorder.Has(record.Plugin) {
+		return nil
+	}
+	inputs, outputs := recorder.Files()
+
+	var m manifest
+	for _, p := range inputs {
+		hash, err := hashPath(p)
+		if err != nil {
+			return err
+		}
+		m.Inputs = append(m.Inputs, input{Path: p, Hash: hash})
+	}
+	for _, name := range recorder.EnvVars() {
+		m.Env = append(m.Env, envVar{Name: name, Hash: hashEnv(name)})
+	}
+	for _, p := range outputs {
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		hash := hashBytes(data)
+		if _, err := os.Stat(c.objectPath(hash)); os.IsNotExist(err) {
+			if err := writeFile(c.objectPath(hash), data, 0644); err != nil {
+				return err
+			}
+		}
+		m.Outputs = append(m.Outputs, output{Path: p, Hash: hash, Mode: info.Mode().Perm()})
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(c.manifestPath(key), data, 0644)
+}
+
+// Restore looks for the entry for the key given, and if all the files
+// used by that run are unchanged, writes the files it wrote, and
+// returns their paths. If there's no entry, or the entry is out of
+// date, it returns false and doesn't write anything.
+func (c *Cache) Restore(key string) ([]string, bool, error) {
+	data, err := ioutil.ReadFile(c.manifestPath(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		// A corrupt entry is just a miss; it'll be overwritten.
+		return nil, false, nil
+	}
+
+	for _, in := range m.Inputs {
+		hash, err := hashPath(in.Path)
+		if err != nil || hash != in.Hash {
+			return nil, false, nil
+		}
+	}
+	for _, env := range m.Env {
+		if hashEnv(env.Name) != env.Hash {
+			return nil, false, nil
+		}
+	}
+
+	// Read all the outputs before writing any, so a missing object
+	// doesn't leave a partial set of files.
+	contents := make([][]byte, len(m.Outputs))
+	for i, out := range m.Outputs {
+		data, err := ioutil.ReadFile(c.objectPath(out.Hash))
+		if err != nil || hashBytes(data) != out.Hash {
+			return nil, false, nil
+		}
+		contents[i] = data
+	}
+
+	var written []string
+	for i, out := range m.Outputs {
+		if err := writeFile(out.Path, contents[i], out.Mode); err != nil {
+			return written, false, err
+		}
+		written = append(written, out.Path)
+	}
+	return written, true, nil
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashEnv returns a hash of the value of the environment variable
+// named, so that the value itself isn't kept in the cache.
+func hashEnv(name string) string {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "unset"
+	}
+	return hashBytes([]byte(v))
+}
+
+// hashPath returns a hash of what's at the path given: the content of
+// a file, the names in a directory, or the fact that there is nothing
+// there (which is also something a run can depend on).
+func hashPath(p string) (string, error) {
+	info, err := os.Stat(p)
+	switch {
+	case os.IsNotExist(err):
+		return "absent", nil
+	case err != nil:
+		return "", err
+	case info.IsDir():
+		f, err := os.Open(p)
+		if err != nil {
+			return "", err
+		}
+		names, err := f.Readdirnames(0)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		sort.Strings(names)
+		h := sha256.New()
+		for _, name := range names {
+			io.WriteString(h, name)
+			h.Write([]byte{0})
+		}
+		return "dir:" + hex.EncodeToString(h.Sum(nil)), nil
+	}
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	return hashBytes(data), nil
+}
+
+// writeFile writes a file atomically (by writing to a temporary file
+// and renaming it), so concurrent runs never see partial output.
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,125 @@
+package runcache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/record"
+)
+
+func TestRunCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jk-runcache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.js")
+	data := filepath.Join(dir, "data")
+	out := filepath.Join(dir, "out", "config.yaml")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("// script"), 0644))
+	assert.NoError(t, os.Mkdir(data, 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(out), 0755))
+	assert.NoError(t, ioutil.WriteFile(out, []byte("a: 1\n"), 0644))
+
+	recorder := &record.Recorder{}
+	recorder.Record(record.ImportFile, record.Params{"specifier": "script.js", "path": script})
+	recorder.Record(record.ListDirectory, record.Params{"path": data})
+	recorder.Record(record.StatFile, record.Params{"path": filepath.Join(dir, "missing.json")})
+	recorder.Record(record.WriteFile, record.Params{"path": out})
+
+	cache := New(filepath.Join(dir, "cache"))
+	key := Key("v1", script)
+	_, ok, err := cache.Restore(key)
+	assert.NoError(t, err)
+	assert.False(t, ok, "nothing stored yet")
+
+	assert.NoError(t, cache.Store(key, recorder))
+
+	// The outputs are restored when nothing has changed
+	assert.NoError(t, os.Remove(out))
+	written, ok, err := cache.Restore(key)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{out}, written)
+	restored, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1\n", string(restored))
+
+	_, ok, _ = cache.Restore(Key("v2", script))
+	assert.False(t, ok, "different key")
+
+	// Each change to an input makes the entry out of date
+	for _, change := range []func(){
+		func() { ioutil.WriteFile(script, []byte("// changed"), 0644) },
+		func() { ioutil.WriteFile(filepath.Join(data, "new.yaml"), nil, 0644) },
+		func() { ioutil.WriteFile(filepath.Join(dir, "missing.json"), nil, 0644) },
+	} {
+		assert.NoError(t, cache.Store(key, recorder))
+		change()
+		_, ok, err := cache.Restore(key)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+}
+
+func TestRunCacheExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jk-runcache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recorder := &record.Recorder{}
+	recorder.Record(record.Exec, record.Params{"command": "git", "args": []string{"describe"}})
+
+	cache := New(filepath.Join(dir, "cache"))
+	key := Key("v1")
+	assert.NoError(t, cache.Store(key, recorder))
+	_, ok, err := cache.Restore(key)
+	assert.NoError(t, err)
+	assert.False(t, ok, "runs that executed commands are not stored")
+}
+
+func TestRunCachePlugin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jk-runcache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recorder := &record.Recorder{}
+	recorder.Record(record.Plugin, record.Params{"plugin": "cmdb", "method": "hosts"})
+
+	cache := New(filepath.Join(dir, "cache"))
+	key := Key("v1")
+	assert.NoError(t, cache.Store(key, recorder))
+	_, ok, err := cache.Restore(key)
+	assert.NoError(t, err)
+	assert.False(t, ok, "runs that called plugins are not stored")
+}
+
+func TestRunCacheEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jk-runcache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv("JK_RUNCACHE_TEST")
+
+	recorder := &record.Recorder{}
+	recorder.Record(record.EnvVar, record.Params{"name": "JK_RUNCACHE_TEST", "set": false})
+
+	cache := New(filepath.Join(dir, "cache"))
+	key := Key("v1")
+	assert.NoError(t, cache.Store(key, recorder))
+	_, ok, _ := cache.Restore(key)
+	assert.True(t, ok)
+
+	os.Setenv("JK_RUNCACHE_TEST", "secret")
+	_, ok, _ = cache.Restore(key)
+	assert.False(t, ok, "variable set since")
+
+	assert.NoError(t, cache.Store(key, recorder))
+	data, err := ioutil.ReadFile(cache.manifestPath(key))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	_, ok, _ = cache.Restore(key)
+	assert.True(t, ok)
+}
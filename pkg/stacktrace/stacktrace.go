@@ -0,0 +1,263 @@
+// Package stacktrace has code for reporting errors in terms of the
+// original source of modules. Modules that have been transpiled or
+// bundled usually carry a source map, referred to by a
+// `//# sourceMappingURL=` comment, either inline (as a `data:` URL) or
+// in a separate file; positions in stack traces are mapped back to the
+// original source using these, and a code frame showing the offending
+// line is printed with the error.
+package stacktrace
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-sourcemap/sourcemap"
+)
+
+// contextLines is the number of lines to show either side of the
+// offending line in a code frame.
+const contextLines = 2
+
+// ReadFunc reads a file referred to by a module (i.e., a source map
+// or an original source file), given a path relative to the module.
+type ReadFunc func(path string) ([]byte, error)
+
+type module struct {
+	source string
+	read   ReadFunc
+	smap   *sourcemap.Consumer
+}
+
+// Sources records the code of modules as they are loaded, and their
+// source maps, so that errors can be reported in terms of the
+// original source.
+type Sources struct {
+	mu      sync.Mutex
+	modules map[string]*module
+}
+
+// NewSources creates a new, empty, Sources.
+func NewSources() *Sources {
+	return &Sources{modules: map[string]*module{}}
+}
+
+var sourceMappingURL = regexp.MustCompile(`(?m)^[ \t]*//[#@][ \t]*sourceMappingURL=(\S+)[ \t]*$`)
+
+// Add records the code of the module with the given name (as used
+// when loading it), and its source map if it refers to one. The read
+// function is used to read a source map in a separate file, and may
+// be nil.
+func (s *Sources) Add(name, source string, read ReadFunc) {
+	m := &module{source: source, read: read}
+	if matches := sourceMappingURL.FindAllStringSubmatch(source, -1); len(matches) > 0 {
+		// The last comment is the one that counts.
+		ref := matches[len(matches)-1][1]
+		if data, err := m.readSourceMap(ref); err == nil {
+			if smap, err := sourcemap.Parse("", data); err == nil {
+				m.smap = smap
+			}
+		}
+	}
+	s.mu.Lock()
+	s.modules[name] = m
+	s.mu.Unlock()
+}
+
+func (m *module) readSourceMap(ref string) ([]byte, error) {
+	if strings.HasPrefix(ref, "data:") {
+		comma := strings.Index(ref, ",")
+		if comma < 0 {
+			return nil, fmt.Errorf("malformed data URL")
+		}
+		meta, data := ref[len("data:"):comma], ref[comma+1:]
+		if strings.HasSuffix(meta, ";base64") {
+			return base64.StdEncoding.DecodeString(data)
+		}
+		unescaped, err := url.PathUnescape(data)
+		return []byte(unescaped), err
+	}
+	if m.read == nil {
+		return nil, fmt.Errorf("cannot read source map %q", ref)
+	}
+	return m.read(ref)
+}
+
+// Position is a position in a source file. Lines and columns are
+// both numbered from 1, as in V8 stack traces.
+type Position struct {
+	Source string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.Source, p.Line, p.Column)
+}
+
+// lookup maps a position in a loaded module to the original source,
+// and returns the content of that source, if known. If the module is
+// not known, or has no source map, the position is returned as it
+// was.
+func (s *Sources) lookup(pos Position) (Position, string, bool) {
+	s.mu.Lock()
+	m, ok := s.modules[pos.Source]
+	s.mu.Unlock()
+	if !ok {
+		return pos, "", false
+	}
+	if m.smap == nil {
+		return pos, m.source, true
+	}
+	source, _, line, column, ok := m.smap.Source(pos.Line, pos.Column-1)
+	if !ok {
+		return pos, m.source, true
+	}
+	content := m.smap.SourceContent(source)
+	if content == "" && m.read != nil {
+		if data, err := m.read(source); err == nil {
+			content = string(data)
+		}
+	}
+	// Relative sources are relative to the module
+	if !path.IsAbs(source) && !strings.Contains(source, "://") {
+		source = path.Join(path.Dir(pos.Source), source)
+	}
+	return Position{Source: source, Line: line, Column: column + 1}, content, true
+}
+
+// Map returns the position in the original source corresponding to
+// the position given, which is in a loaded module.
+func (s *Sources) Map(pos Position) Position {
+	orig, _, _ := s.lookup(pos)
+	return orig
+}
+
+// Frame returns a code frame showing the line at the position given
+// (which is in a loaded module), in its original source, with a
+// marker at the column. If the source is not known, the empty string
+// is returned.
+func (s *Sources) Frame(pos Position) string {
+	orig, content, ok := s.lookup(pos)
+	if !ok || content == "" {
+		return ""
+	}
+	return codeFrame(content, orig.Line, orig.Column)
+}
+
+func codeFrame(content string, line, column int) string {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	first, last := line-contextLines, line+contextLines
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+	width := len(strconv.Itoa(last))
+
+	var b strings.Builder
+	for n := first; n <= last; n++ {
+		text := strings.TrimRight(lines[n-1], "\r")
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %*d | %s\n", marker, width, n, text)
+		if n == line && column > 0 {
+			// Copy tabs from the line, so the caret lines up
+			var pad strings.Builder
+			for i, c := range []rune(text) {
+				if i >= column-1 {
+					break
+				}
+				if c == '\t' {
+					pad.WriteRune('\t')
+				} else {
+					pad.WriteRune(' ')
+				}
+			}
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", pad.String())
+		}
+	}
+	return b.String()
+}
+
+var stackFrame = regexp.MustCompile(`^(\s+at (?:.* \()?)(.+):(\d+):(\d+)(\)?)$`)
+
+func parseFrame(line string) (Position, []string, bool) {
+	m := stackFrame.FindStringSubmatch(line)
+	if m == nil {
+		return Position{}, nil, false
+	}
+	lineNum, _ := strconv.Atoi(m[3])
+	column, _ := strconv.Atoi(m[4])
+	return Position{Source: m[2], Line: lineNum, Column: column}, m, true
+}
+
+// RewriteStack rewrites the positions in a stack trace (as given by
+// `err.stack`) so they refer to the original source.
+func (s *Sources) RewriteStack(stack string) string {
+	lines := strings.Split(stack, "\n")
+	for i, line := range lines {
+		pos, m, ok := parseFrame(line)
+		if !ok {
+			continue
+		}
+		lines[i] = m[1] + s.Map(pos).String() + m[5]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TopFrame returns the position of the first frame in a stack trace,
+// if there is one.
+func TopFrame(stack string) (Position, bool) {
+	for _, line := range strings.Split(stack, "\n") {
+		if pos, _, ok := parseFrame(line); ok {
+			return pos, true
+		}
+	}
+	return Position{}, false
+}
+
+var (
+	exceptionLocation = regexp.MustCompile(`^(.+):(\d+)$`)
+	exceptionMarker   = regexp.MustCompile(`^( *)\^+$`)
+)
+
+// FormatException rewrites an exception as reported by V8 (e.g., in
+// the error returned from loading a module), which looks like
+//
+//     <module>:<line>
+//     <source line>
+//         ^^^^^
+//     <stack trace, or the exception>
+//
+// so that the location refers to the original source and is shown as
+// a code frame, and the stack trace is rewritten.
+func (s *Sources) FormatException(exception string) string {
+	lines := strings.SplitN(exception, "\n", 4)
+	if len(lines) < 4 {
+		return s.RewriteStack(exception)
+	}
+	loc, marker := exceptionLocation.FindStringSubmatch(lines[0]), exceptionMarker.FindStringSubmatch(lines[2])
+	if loc == nil || marker == nil {
+		return s.RewriteStack(exception)
+	}
+
+	line, _ := strconv.Atoi(loc[2])
+	pos := Position{Source: loc[1], Line: line, Column: len(marker[1]) + 1}
+	frame := s.Frame(pos)
+	if frame == "" {
+		return strings.Join(lines[:3], "\n") + "\n" + s.RewriteStack(lines[3])
+	}
+	return s.Map(pos).String() + "\n" + frame + s.RewriteStack(lines[3])
+}
@@ -0,0 +1,108 @@
+package stacktrace
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/stretchr/testify/assert"
+)
+
+const original = `interface Thing {
+  name: string;
+}
+
+export function fail(t: Thing): never {
+  throw new Error(t.name);
+}
+`
+
+// transpile gives the JavaScript for the source above, and a source
+// map for it.
+func transpile(t *testing.T, sourcemap api.SourceMap) (string, string) {
+	result := api.Transform(original, api.TransformOptions{
+		Loader:     api.LoaderTS,
+		Sourcemap:  sourcemap,
+		Sourcefile: "/src/thing.ts",
+	})
+	if !assert.Empty(t, result.Errors) {
+		t.FailNow()
+	}
+	return string(result.Code), string(result.Map)
+}
+
+// throwLine finds the (generated) line and column of the throw in the
+// code given.
+func throwLine(code string) (int, int) {
+	for i, line := range strings.Split(code, "\n") {
+		if col := strings.Index(line, "throw"); col >= 0 {
+			return i + 1, col + 1
+		}
+	}
+	return 0, 0
+}
+
+func TestInlineSourceMap(t *testing.T) {
+	code, _ := transpile(t, api.SourceMapInline)
+	sources := NewSources()
+	sources.Add("/out/thing.js", code, nil)
+
+	line, col := throwLine(code)
+	pos := sources.Map(Position{"/out/thing.js", line, col})
+	assert.Equal(t, Position{"/src/thing.ts", 6, 3}, pos)
+
+	stack := fmt.Sprintf("Error: oops\n    at fail (/out/thing.js:%d:%d)\n    at /main.js:1:1", line, col)
+	assert.Equal(t, "Error: oops\n    at fail (/src/thing.ts:6:3)\n    at /main.js:1:1", sources.RewriteStack(stack))
+}
+
+func TestSidecarSourceMap(t *testing.T) {
+	code, smap := transpile(t, api.SourceMapExternal)
+	code += "//# sourceMappingURL=thing.js.map\n"
+	sources := NewSources()
+	var read []string
+	sources.Add("/out/thing.js", code, func(p string) ([]byte, error) {
+		read = append(read, p)
+		return []byte(smap), nil
+	})
+	assert.Equal(t, []string{"thing.js.map"}, read)
+
+	line, col := throwLine(code)
+	assert.Equal(t, Position{"/src/thing.ts", 6, 3}, sources.Map(Position{"/out/thing.js", line, col}))
+}
+
+func TestFrame(t *testing.T) {
+	code, _ := transpile(t, api.SourceMapInline)
+	sources := NewSources()
+	sources.Add("/out/thing.js", code, nil)
+
+	line, col := throwLine(code)
+	expected := `  4 | 
+  5 | export function fail(t: Thing): never {
+> 6 |   throw new Error(t.name);
+    |   ^
+  7 | }
+  8 | 
+`
+	assert.Equal(t, expected, sources.Frame(Position{"/out/thing.js", line, col}))
+	assert.Equal(t, "", sources.Frame(Position{"/unknown.js", 1, 1}))
+}
+
+func TestFormatException(t *testing.T) {
+	sources := NewSources()
+	sources.Add("main.js", "const a = 1;\nconst b = ;\n", nil)
+
+	exception := "main.js:2\nconst b = ;\n          ^\nSyntaxError: Unexpected token ;\n"
+	expected := `main.js:2:11
+  1 | const a = 1;
+> 2 | const b = ;
+    |           ^
+  3 | 
+SyntaxError: Unexpected token ;
+`
+	assert.Equal(t, expected, sources.FormatException(exception))
+
+	// Unknown modules are left alone
+	unknown := strings.Replace(exception, "main.js", "other.js", 1)
+	assert.Equal(t, unknown, sources.FormatException(unknown))
+}
@@ -0,0 +1,253 @@
+package std
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jkcfg/jk/pkg/schema"
+)
+
+// ParamDeclaration is what a script declares about one of its
+// parameters, with param.declare.
+type ParamDeclaration struct {
+	Path string `json:"path"`
+	// Type is one of boolean, number, string, object or array.
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	// Schema is a JSON Schema the value must satisfy, if given.
+	Schema map[string]interface{} `json:"schema,omitempty"`
+}
+
+var paramTypes = map[string]bool{
+	"boolean": true,
+	"number":  true,
+	"string":  true,
+	"object":  true,
+	"array":   true,
+}
+
+// ParamDeclarations collects the parameter declarations made by the
+// scripts run.
+type ParamDeclarations struct {
+	mu     sync.Mutex
+	byPath map[string]ParamDeclaration
+	order  []string
+}
+
+// NewParamDeclarations creates an empty set of declarations.
+func NewParamDeclarations() *ParamDeclarations {
+	return &ParamDeclarations{byPath: map[string]ParamDeclaration{}}
+}
+
+// add adds a declaration. Declaring the same parameter again is fine,
+// so long as it's with the same type.
+func (d *ParamDeclarations) add(decl ParamDeclaration) error {
+	if !paramTypes[decl.Type] {
+		return fmt.Errorf("parameter %s: unknown type %q", decl.Path, decl.Type)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if prev, ok := d.byPath[decl.Path]; ok {
+		if prev.Type != decl.Type {
+			return fmt.Errorf("parameter %s: declared as %s, and before as %s", decl.Path, decl.Type, prev.Type)
+		}
+	} else {
+		d.order = append(d.order, decl.Path)
+	}
+	d.byPath[decl.Path] = decl
+	return nil
+}
+
+// lookup returns the declaration for the path given, if there is one.
+func (d *ParamDeclarations) lookup(path string) (ParamDeclaration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	decl, ok := d.byPath[path]
+	return decl, ok
+}
+
+// All returns the declarations, in the order they were made.
+func (d *ParamDeclarations) All() []ParamDeclaration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	all := make([]ParamDeclaration, len(d.order))
+	for i, p := range d.order {
+		all[i] = d.byPath[p]
+	}
+	return all
+}
+
+// Unknown returns the paths of the parameters given that are neither
+// declared, nor part of a declared (object or array) parameter. The
+// parameters under `jk`, which jk sets for its own commands, are not
+// included.
+func (d *ParamDeclarations) Unknown(params Params) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var unknown []string
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			path := prefix + k
+			if path == "jk" {
+				continue
+			}
+			if _, ok := d.byPath[path]; ok {
+				continue
+			}
+			if sub, ok := m[k].(map[string]interface{}); ok && len(sub) > 0 {
+				walk(path+".", sub)
+				continue
+			}
+			unknown = append(unknown, path)
+		}
+	}
+	walk("", params)
+	return unknown
+}
+
+// check checks the value supplied for a declared parameter, if there
+// is one, against the declaration.
+func (decl ParamDeclaration) check(params Params) error {
+	if _, err := params.Get(decl.Path); err != nil {
+		if decl.Required {
+			return fmt.Errorf("parameter %s is required", decl.Path)
+		}
+		return nil
+	}
+
+	var (
+		v   interface{}
+		err error
+	)
+	switch decl.Type {
+	case "boolean":
+		v, err = params.GetBool(decl.Path)
+	case "number":
+		v, err = params.GetNumber(decl.Path)
+	case "string":
+		v, err = params.GetString(decl.Path)
+	case "object":
+		v, err = params.GetObject(decl.Path)
+	case "array":
+		v, err = params.GetArray(decl.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid type for param '%s': %v", decl.Path, err)
+	}
+	if decl.Schema == nil {
+		return nil
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s, err := json.Marshal(decl.Schema)
+	if err != nil {
+		return err
+	}
+	errs, err := schema.ValidateWithObject(string(value), string(s))
+	if err != nil {
+		return fmt.Errorf("parameter %s: %v", decl.Path, err)
+	}
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i := range errs {
+			msgs[i] = errs[i].Msg
+		}
+		return fmt.Errorf("parameter %s: %s", decl.Path, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// declareParams is the RPC method std.param.declare. The argument is
+// an object of parameter paths to declarations. Unless validation is
+// skipped, the parameters supplied for those declared are checked,
+// so that a script declaring its parameters before using them fails
+// before doing anything with bad values.
+func declareParams(options Options, args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, argsError("expected one argument")
+	}
+	data, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var decls map[string]ParamDeclaration
+	if err := json.Unmarshal(data, &decls); err != nil {
+		return nil, argsError(fmt.Sprintf("expected object of declarations: %v", err))
+	}
+
+	paths := make([]string, 0, len(decls))
+	for p := range decls {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		decl := decls[p]
+		decl.Path = p
+		if err := options.Declarations.add(decl); err != nil {
+			return nil, err
+		}
+		if options.SkipParamValidation {
+			continue
+		}
+		if err := decl.check(options.Parameters); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// checkUnknownParams returns an error if, in strict mode, parameters
+// have been supplied that haven't been declared.
+func checkUnknownParams(options Options) error {
+	if !options.StrictParams || options.SkipParamValidation {
+		return nil
+	}
+	if unknown := options.Declarations.Unknown(options.Parameters); len(unknown) > 0 {
+		return fmt.Errorf("unknown parameters (not declared with param.declare): %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// CheckDeclaredParams checks the parameters given against the
+// declarations given (e.g., those collected by running a script with
+// SkipParamValidation), as param.declare does. If strict, it's also
+// an error to give parameters that aren't declared.
+func CheckDeclaredParams(decls []ParamDeclaration, params Params, strict bool) error {
+	declared := NewParamDeclarations()
+	for _, decl := range decls {
+		if err := declared.add(decl); err != nil {
+			return err
+		}
+		if err := decl.check(params); err != nil {
+			return err
+		}
+	}
+	return checkUnknownParams(Options{Parameters: params, Declarations: declared, StrictParams: strict})
+}
+
+// CheckParams returns an error if, in strict mode, parameters have
+// been supplied that the scripts run haven't declared. This is also
+// checked before each write, so nothing is written when there are
+// unknown parameters.
+func (s *Std) CheckParams() error {
+	return checkUnknownParams(s.options)
+}
+
+// Declarations returns the parameter declarations made so far.
+func (s *Std) Declarations() []ParamDeclaration {
+	return s.options.Declarations.All()
+}
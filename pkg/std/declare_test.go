@@ -0,0 +1,112 @@
+package std
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/__std"
+)
+
+func declareOptions(params Params) Options {
+	return Options{Parameters: params, Declarations: NewParamDeclarations()}
+}
+
+func testDeclarations() map[string]interface{} {
+	return map[string]interface{}{
+		"name": map[string]interface{}{"type": "string", "required": true},
+		"replicas": map[string]interface{}{
+			"type":        "number",
+			"description": "how many to run",
+			"default":     1.0,
+			"schema":      map[string]interface{}{"minimum": 1.0, "maximum": 10.0},
+		},
+		"labels": map[string]interface{}{"type": "object"},
+	}
+}
+
+func TestDeclareParams(t *testing.T) {
+	options := declareOptions(p(`{ "name": "web", "replicas": "3" }`))
+	_, err := declareParams(options, []interface{}{testDeclarations()})
+	assert.NoError(t, err)
+
+	decls := options.Declarations.All()
+	assert.Equal(t, []string{"labels", "name", "replicas"}, []string{decls[0].Path, decls[1].Path, decls[2].Path})
+	assert.Equal(t, ParamDeclaration{
+		Path:        "replicas",
+		Type:        "number",
+		Description: "how many to run",
+		Default:     1.0,
+		Schema:      map[string]interface{}{"minimum": 1.0, "maximum": 10.0},
+	}, decls[2])
+
+	// declaring again is fine, but not with a different type
+	_, err = declareParams(options, []interface{}{testDeclarations()})
+	assert.NoError(t, err)
+	_, err = declareParams(options, []interface{}{map[string]interface{}{"name": map[string]interface{}{"type": "number"}}})
+	assert.Error(t, err)
+	_, err = declareParams(options, []interface{}{map[string]interface{}{"other": map[string]interface{}{"type": "date"}}})
+	assert.Error(t, err)
+}
+
+func TestDeclareParamsInvalid(t *testing.T) {
+	for _, test := range []struct {
+		params string
+		err    string
+	}{
+		{`{ "replicas": 2 }`, "parameter name is required"},
+		{`{ "name": "web", "replicas": "lots" }`, `invalid type for param 'replicas': cannot convert "lots" to float64`},
+		{`{ "name": "web", "replicas": 20 }`, "parameter replicas: Must be less than or equal to 10"},
+	} {
+		_, err := declareParams(declareOptions(p(test.params)), []interface{}{testDeclarations()})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+
+	// nothing is checked when only collecting declarations
+	options := declareOptions(p(`{}`))
+	options.SkipParamValidation = true
+	_, err := declareParams(options, []interface{}{testDeclarations()})
+	assert.NoError(t, err)
+}
+
+func TestUnknownParams(t *testing.T) {
+	options := declareOptions(p(`{ "name": "web", "labels": { "app": "web" }, "nmae": "typo", "db": { "host": "x" }, "jk": { "transform": {} } }`))
+	_, err := declareParams(options, []interface{}{testDeclarations()})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db.host", "nmae"}, options.Declarations.Unknown(options.Parameters))
+
+	assert.NoError(t, checkUnknownParams(options))
+	options.StrictParams = true
+	assert.EqualError(t, checkUnknownParams(options), "unknown parameters (not declared with param.declare): db.host, nmae")
+}
+
+func TestCheckDeclaredParams(t *testing.T) {
+	// collect the declarations, as `jk params` does, then check them
+	options := declareOptions(p(`{}`))
+	options.SkipParamValidation = true
+	_, err := declareParams(options, []interface{}{testDeclarations()})
+	assert.NoError(t, err)
+	decls := options.Declarations.All()
+
+	assert.NoError(t, CheckDeclaredParams(decls, p(`{ "name": "web", "other": 1 }`), false))
+	assert.EqualError(t, CheckDeclaredParams(decls, p(`{ "replicas": 2 }`), false), "parameter name is required")
+	assert.EqualError(t, CheckDeclaredParams(decls, p(`{ "name": "web", "other": 1 }`), true), "unknown parameters (not declared with param.declare): other")
+}
+
+func TestDeclaredDefault(t *testing.T) {
+	options := declareOptions(p(`{ "name": "web" }`))
+	_, err := declareParams(options, []interface{}{testDeclarations()})
+	assert.NoError(t, err)
+
+	v, err := param(options.Parameters, options.Declarations, __std.ParamTypeNumber, "replicas", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(v))
+	v, err = param(options.Parameters, options.Declarations, __std.ParamTypeString, "name", "")
+	assert.NoError(t, err)
+	assert.Equal(t, `"web"`, string(v))
+	v, err = param(options.Parameters, options.Declarations, __std.ParamTypeObject, "labels", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(v))
+}
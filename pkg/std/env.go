@@ -0,0 +1,49 @@
+package std
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jkcfg/jk/pkg/record"
+)
+
+// envAllowed says whether the environment variable named matches one
+// of the patterns given, which are as for path.Match (e.g., `CI_*`).
+func envAllowed(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupEnv looks up an environment variable for std.env. Only the
+// variables matching options.EnvAllow may be looked up. The result is
+// nil if the variable is not set. Each lookup is recorded, without
+// the value, so that a change to the variable can be noticed.
+func lookupEnv(options Options, args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, argsError("expected one argument")
+	}
+	name, err := stringArg(args[0], "name")
+	if err != nil {
+		return nil, err
+	}
+	if !envAllowed(options.EnvAllow, name) {
+		return nil, fmt.Errorf("std.env: %q is not an allowed environment variable", name)
+	}
+
+	value, ok := os.LookupEnv(name)
+	if recorder := options.Sandbox.Recorder; recorder != nil {
+		recorder.Record(record.EnvVar, record.Params{
+			"name": name,
+			"set":  ok,
+		})
+	}
+	if !ok {
+		return nil, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,40 @@
+package std
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/record"
+)
+
+func TestLookupEnv(t *testing.T) {
+	os.Setenv("JK_TEST_SET", "value")
+	defer os.Unsetenv("JK_TEST_SET")
+	os.Unsetenv("JK_TEST_UNSET")
+
+	recorder := &record.Recorder{}
+	options := Options{
+		EnvAllow: []string{"JK_TEST_*"},
+		Sandbox:  Sandbox{Recorder: recorder},
+	}
+
+	v, err := lookupEnv(options, []interface{}{"JK_TEST_SET"})
+	assert.NoError(t, err)
+	assert.Equal(t, "value", v)
+	v, err = lookupEnv(options, []interface{}{"JK_TEST_UNSET"})
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	// names are recorded, but values never are
+	data, _ := recorder.MarshalJSON()
+	assert.JSONEq(t, `[{"kind": "env-var", "name": "JK_TEST_SET", "set": true}, {"kind": "env-var", "name": "JK_TEST_UNSET", "set": false}]`, string(data))
+
+	_, err = lookupEnv(options, []interface{}{"HOME"})
+	assert.EqualError(t, err, `std.env: "HOME" is not an allowed environment variable`)
+	_, err = lookupEnv(Options{}, []interface{}{"JK_TEST_SET"})
+	assert.Error(t, err)
+	_, err = lookupEnv(options, []interface{}{1.0})
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package std
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/jkcfg/jk/pkg/record"
+)
+
+// execResult is what std.exec gives back to the script.
+type execResult struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	Code   int    `json:"code"`
+}
+
+func commandAllowed(allowed []string, command string) bool {
+	for _, c := range allowed {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+func stringArg(v interface{}, what string) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", argsError(fmt.Sprintf("expected string for %s", what))
+	}
+	return s, nil
+}
+
+// execCommand runs a command for std.exec. The arguments are the
+// command, its arguments, extra environment entries, and what to give
+// it on stdin. Only the commands in options.Commands may be run, and
+// none are run in a dry run. A command exiting with a non-zero code is
+// not an error; its code is returned along with its output.
+func execCommand(options Options, args []interface{}) (interface{}, error) {
+	if len(args) != 4 {
+		return nil, argsError("expected command, args, env, stdin")
+	}
+	command, err := stringArg(args[0], "command")
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs, ok := args[1].([]interface{})
+	if !ok && args[1] != nil {
+		return nil, argsError("expected array of strings for args")
+	}
+	env, ok := args[2].(map[string]interface{})
+	if !ok && args[2] != nil {
+		return nil, argsError("expected object for env")
+	}
+	stdin, err := stringArg(args[3], "stdin")
+	if err != nil {
+		return nil, err
+	}
+
+	if options.DryRun {
+		return nil, errors.New("std.exec: commands are not run when emitting dependencies or listing parameters")
+	}
+	if !commandAllowed(options.Commands, command) {
+		return nil, fmt.Errorf("std.exec: %q is not an allowed command", command)
+	}
+
+	var argv []string
+	for i := range cmdArgs {
+		arg, err := stringArg(cmdArgs[i], fmt.Sprintf("args[%d]", i))
+		if err != nil {
+			return nil, err
+		}
+		argv = append(argv, arg)
+	}
+
+	cmd := exec.Command(command, argv...)
+	if len(env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range env {
+			value, err := stringArg(v, fmt.Sprintf("env.%s", k))
+			if err != nil {
+				return nil, err
+			}
+			cmd.Env = append(cmd.Env, k+"="+value)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewBufferString(stdin)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	if recorder := options.Sandbox.Recorder; recorder != nil {
+		recorder.Record(record.Exec, record.Params{
+			"command": command,
+			"args":    argv,
+		})
+	}
+
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return execResult{Stdout: stdout.String(), Stderr: stderr.String(), Code: exitErr.ExitCode()}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("std.exec: %v", err)
+	}
+	return execResult{Stdout: stdout.String(), Stderr: stderr.String()}, nil
+}
@@ -0,0 +1,41 @@
+package std
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/record"
+)
+
+func TestExecCommand(t *testing.T) {
+	recorder := &record.Recorder{}
+	options := Options{
+		Commands: []string{"sh"},
+		Sandbox:  Sandbox{Recorder: recorder},
+	}
+
+	result, err := execCommand(options, []interface{}{
+		"sh",
+		[]interface{}{"-c", `read line; echo "$line $GREETING"; echo oops >&2; exit 3`},
+		map[string]interface{}{"GREETING": "world"},
+		"hello\n",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, execResult{Stdout: "hello world\n", Stderr: "oops\n", Code: 3}, result)
+
+	assert.True(t, recorder.Has(record.Exec))
+	data, _ := recorder.MarshalJSON()
+	assert.JSONEq(t, `[{"kind": "exec", "command": "sh", "args": ["-c", "read line; echo \"$line $GREETING\"; echo oops >&2; exit 3"]}]`, string(data))
+
+	// Commands not allowed are not run
+	_, err = execCommand(options, []interface{}{"ls", nil, nil, ""})
+	assert.EqualError(t, err, `std.exec: "ls" is not an allowed command`)
+	_, err = execCommand(Options{}, []interface{}{"sh", nil, nil, ""})
+	assert.Error(t, err)
+
+	// and nothing is run in a dry run
+	options.DryRun = true
+	_, err = execCommand(options, []interface{}{"sh", nil, nil, ""})
+	assert.EqualError(t, err, "std.exec: commands are not run when emitting dependencies or listing parameters")
+}
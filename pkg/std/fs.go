@@ -5,6 +5,7 @@ import (
 	"path"
 	"sort"
 
+	"github.com/jkcfg/jk/pkg/record"
 	"github.com/jkcfg/jk/pkg/vfs"
 
 	"github.com/shurcooL/httpfs/vfsutil"
@@ -32,6 +33,7 @@ func MakeFileInfo(r Sandbox, path, module string) (FileInfo, error) {
 	if err != nil {
 		return FileInfo{}, err
 	}
+	r.recordRead(record.StatFile, loc)
 	return fileInfo(loc, path)
 }
 
@@ -42,6 +44,7 @@ func MakeDirectoryListing(r Sandbox, p, module string) (Directory, error) {
 	if err != nil {
 		return Directory{}, err
 	}
+	r.recordRead(record.ListDirectory, loc)
 	return directoryListing(loc, p)
 }
 
@@ -8,10 +8,19 @@ import (
 	"github.com/jkcfg/jk/pkg/__std"
 )
 
-func param(params Params, kind __std.ParamType, path string, defaultValue string) ([]byte, error) {
+func param(params Params, decls *ParamDeclarations, kind __std.ParamType, path string, defaultValue string) ([]byte, error) {
 	var v interface{}
 	var err error
 
+	// A declared default takes the place of one given where the
+	// parameter is looked up.
+	decl, declared := decls.lookup(path)
+	if declared && decl.Default != nil {
+		if data, err := json.Marshal(decl.Default); err == nil {
+			defaultValue = string(data)
+		}
+	}
+
 	switch kind {
 	case __std.ParamTypeBoolean:
 		v, err = params.GetBool(path)
@@ -31,6 +40,8 @@ func param(params Params, kind __std.ParamType, path string, defaultValue string
 		base, _ := NewParamsFromJSON(r)
 		base.Merge(v.(Params))
 		v = base
+	case __std.ParamTypeArray:
+		v, err = params.GetArray(path)
 	default:
 		panic("param: unexpected kind")
 	}
@@ -40,6 +51,9 @@ func param(params Params, kind __std.ParamType, path string, defaultValue string
 	} else if err != nil {
 		// Path not found. This is not an error, the std lib will return the parameter
 		// default value.
+		if declared && decl.Default != nil {
+			return []byte(defaultValue), nil
+		}
 		return []byte("null"), nil
 	}
 
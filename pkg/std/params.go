@@ -62,6 +62,96 @@ func NewParamsFromFile(path string) (Params, error) {
 	return decode(f)
 }
 
+// NewParamsFromEnv creates Params from the environment entries given
+// (as from os.Environ) that have the prefix given, followed by an
+// underscore. The rest of the name is lowercased, and split on double
+// underscores to give the path of the parameter; e.g., with the prefix
+// `APP`, `APP_DB__HOST_NAME=localhost` sets `db.host_name`. The values
+// are all strings, and coerced as for parameters given on the command
+// line.
+func NewParamsFromEnv(prefix string, environ []string) Params {
+	p := NewParams()
+	prefix = strings.TrimSuffix(prefix, "_") + "_"
+	for _, entry := range environ {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], prefix) {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(parts[0], prefix))
+		path := strings.Split(name, "__")
+		valid := true
+		for _, part := range path {
+			if part == "" {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			p.SetString(strings.Join(path, "."), parts[1])
+		}
+	}
+	return p
+}
+
+// pathElement is one step in a parameter path: a key in an object,
+// or, when index is not negative, an index into an array.
+type pathElement struct {
+	key   string
+	index int
+}
+
+// parsePath splits a parameter path into its elements. A path is a
+// series of keys separated by dots, each of which may be followed by
+// array indices; e.g., `a.b[2].c`. A part of the path that isn't of
+// that form is taken to be a key as it is.
+func parsePath(path string) []pathElement {
+	var elements []pathElement
+	for _, part := range strings.Split(path, ".") {
+		elements = append(elements, parsePathPart(part)...)
+	}
+	return elements
+}
+
+func parsePathPart(part string) []pathElement {
+	literal := []pathElement{{key: part, index: -1}}
+	i := strings.IndexByte(part, '[')
+	if i <= 0 {
+		return literal
+	}
+	elements := []pathElement{{key: part[:i], index: -1}}
+	for rest := part[i:]; rest != ""; {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return literal
+		}
+		n, err := strconv.Atoi(rest[1:end])
+		if err != nil || n < 0 {
+			return literal
+		}
+		elements = append(elements, pathElement{index: n})
+		rest = rest[end+1:]
+	}
+	return elements
+}
+
+// pathString gives back the path made of the elements given, for use
+// in error messages.
+func pathString(elements []pathElement) string {
+	var b strings.Builder
+	for i, e := range elements {
+		switch {
+		case e.index >= 0:
+			fmt.Fprintf(&b, "[%d]", e.index)
+		case i > 0:
+			b.WriteString(".")
+			fallthrough
+		default:
+			b.WriteString(e.key)
+		}
+	}
+	return b.String()
+}
+
 // Get retrieves a parameter.
 func (p Params) Get(path string) (interface{}, error) {
 	// "" has the special meaning of "all of p"
@@ -69,33 +159,38 @@ func (p Params) Get(path string) (interface{}, error) {
 		return p, nil
 	}
 
-	parts := strings.Split(path, ".")
-	m := p
-	for i, part := range parts {
-		v, found := m[part]
-		if !found {
-			return nil, fmt.Errorf("invalid path (key not found): %s", strings.Join(parts[:i+1], "."))
-		}
-		// Found the value!
-		if i == len(parts)-1 {
-			// When the value is a sub-object (as opposed to a primitive value), we box
-			// it into a Params to keep the nice property that a sub-tree of Params is
-			// still a Params.
-			if retMap, ok := v.(map[string]interface{}); ok {
-				return Params(retMap), nil
+	elements := parsePath(path)
+	var v interface{} = map[string]interface{}(p)
+	for i, e := range elements {
+		if e.index >= 0 {
+			a, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid path (value isn't an array): %s", pathString(elements[:i]))
 			}
-			return v, nil
+			if e.index >= len(a) {
+				return nil, fmt.Errorf("invalid path (index out of range): %s", pathString(elements[:i+1]))
+			}
+			v = a[e.index]
+			continue
 		}
 		// We can only continue if we're traversing a map.
-		if newMap, ok := v.(map[string]interface{}); ok {
-			m = Params(newMap)
-			continue
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid path (key isn't a map): %s", pathString(elements[:i]))
+		}
+		v, ok = m[e.key]
+		if !ok {
+			return nil, fmt.Errorf("invalid path (key not found): %s", pathString(elements[:i+1]))
 		}
-		return nil, fmt.Errorf("invalid path (key isn't a map): %s", strings.Join(parts[:i+1], "."))
 	}
 
-	// We shouldn't reach this.
-	return nil, fmt.Errorf("invalid path (eek!): %s", path)
+	// When the value is a sub-object (as opposed to a primitive value), we box
+	// it into a Params to keep the nice property that a sub-tree of Params is
+	// still a Params.
+	if m, ok := v.(map[string]interface{}); ok {
+		return Params(m), nil
+	}
+	return v, nil
 }
 
 // GetBool retrieves a boolean parameter.
@@ -158,37 +253,58 @@ func (p Params) GetObject(path string) (Params, error) {
 	return NewParams(), fmt.Errorf("cannot convert %q to Params", v)
 }
 
-func isMap(v interface{}) bool {
-	_, ok := v.(map[string]interface{})
-	return ok
-}
-
-// Set sets a parameter.
-func (p Params) Set(path string, v interface{}) {
-	parts := strings.Split(path, ".")
-	m := p
-	for i := 0; i < len(parts)-1; i++ {
-		part := parts[i]
-		value, ok := m[part]
-		// If the part key doesn't exist yet or is primitive type, create it.
-		if !ok || !isMap(value) {
-			p := make(map[string]interface{})
-			m[part] = p
-			m = p
-			continue
+// GetArray retrieves an array parameter.
+func (p Params) GetArray(path string) ([]interface{}, error) {
+	v, err := p.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if a, ok := v.([]interface{}); ok {
+		return a, nil
+	}
+	// string -> array coercion, for arrays given as JSON.
+	if s, ok := v.(string); ok {
+		var a []interface{}
+		if err := json.Unmarshal([]byte(s), &a); err == nil && a != nil {
+			return a, nil
 		}
-		// Continue through the chain of maps
-		m = m[part].(map[string]interface{})
 	}
+	return nil, fmt.Errorf("cannot convert %q to array", v)
+}
 
-	// Internal types are only primitive types and maps, not Params. Convert Params
-	// to map[string]interface{}.
+// Set sets a parameter. Objects and arrays along the path are created
+// as needed, replacing any values of another type; arrays are grown to
+// have the index given, with null for any elements skipped.
+func (p Params) Set(path string, v interface{}) {
+	// Internal types are only primitive types, arrays and maps, not
+	// Params. Convert Params to map[string]interface{}.
 	if p, ok := v.(Params); ok {
 		v = map[string]interface{}(p)
 	}
+	setIn(map[string]interface{}(p), parsePath(path), v)
+}
 
-	key := parts[len(parts)-1]
-	m[key] = v
+// setIn sets the value at the path given within container, and returns
+// the container, which is new if it had to be created or grown.
+func setIn(container interface{}, elements []pathElement, v interface{}) interface{} {
+	if len(elements) == 0 {
+		return v
+	}
+	e := elements[0]
+	if e.index >= 0 {
+		a, _ := container.([]interface{})
+		for len(a) <= e.index {
+			a = append(a, nil)
+		}
+		a[e.index] = setIn(a[e.index], elements[1:], v)
+		return a
+	}
+	m, ok := container.(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+	}
+	m[e.key] = setIn(m[e.key], elements[1:], v)
+	return m
 }
 
 // SetBool sets a boolean parameter.
@@ -211,6 +327,11 @@ func (p Params) SetObject(path string, o Params) {
 	p.Set(path, o)
 }
 
+// SetArray sets an array parameter.
+func (p Params) SetArray(path string, a []interface{}) {
+	p.Set(path, a)
+}
+
 // Merge merges two parameter stores.
 func (p Params) Merge(a Params) {
 	for k, v := range a {
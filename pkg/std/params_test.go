@@ -45,6 +45,14 @@ func TestGet(t *testing.T) {
 		{p(`{ "foo": { "bar": "baz" } }`), "", true, p(`{ "foo": { "bar": "baz" } }`)},
 		{p(`{ "foo": { "bar": { "baz": 3 } } }`), "foo.bar", true, p(`{ "baz": 3 }`)},
 		{p(`{ "xxx": "yyy", "foo": { "bar": { "baz": 3 } } }`), "foo.bar", true, p(`{ "baz": 3 }`)},
+		// array indices
+		{p(`{ "foo": [1, 2, 3] }`), "foo[1]", true, float64(2)},
+		{p(`{ "foo": [1, 2, 3] }`), "foo[3]", false, nil},
+		{p(`{ "foo": { "bar": 2 } }`), "foo[0]", false, nil},
+		{p(`{ "foo": [{ "bar": [[0, "baz"]] }] }`), "foo[0].bar[0][1]", true, "baz"},
+		{p(`{ "foo": [{ "bar": 2 }] }`), "foo[0]", true, p(`{ "bar": 2 }`)},
+		// not index syntax, so a key as it is
+		{p(`{ "foo[x]": 2 }`), "foo[x]", true, float64(2)},
 	}
 
 	for _, test := range tests {
@@ -75,6 +83,12 @@ func TestTypedGet(t *testing.T) {
 	_, err = params.GetString("foo.bar.baz")
 	assert.Error(t, err)
 
+	vArray, err := p(`{ "a": [1, "two"] }`).GetArray("a")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{1.0, "two"}, vArray)
+	_, err = params.GetArray("foo.bar")
+	assert.Error(t, err)
+
 	vObject, err := params.GetObject("foo.bar")
 	assert.NoError(t, err)
 	assert.Equal(t, p(`{ "baz": 3 }`), vObject)
@@ -99,7 +113,13 @@ func TestCoercion(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, true, vBool)
 
+	vArray, err := p(`{ "a": "[1, 2]" }`).GetArray("a")
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{1.0, 2.0}, vArray)
+
 	// Invalid coercion.
+	_, err = params.GetArray("n")
+	assert.Error(t, err)
 	_, err = params.GetNumber("b")
 	assert.Error(t, err)
 	_, err = params.GetBool("n")
@@ -118,6 +138,11 @@ func TestSet(t *testing.T) {
 		{p(`{}`), "foo", true, p(`{ "foo": true }`)},
 		{p(`{}`), "foo", p(`{ "bar": "baz" } `), p(`{ "foo": { "bar": "baz" } }`)},
 		{p(`{ "foo": { "xxx": 42 } }`), "foo.yyy", p(`{ "bar": "baz" } `), p(`{ "foo": { "xxx": 42, "yyy": { "bar": "baz" } } }`)},
+		{p(`{}`), "foo", []interface{}{"a", 1.0}, p(`{ "foo": ["a", 1] }`)},
+		{p(`{}`), "foo[1].bar", "baz", p(`{ "foo": [null, { "bar": "baz" }] }`)},
+		{p(`{ "foo": [1, 2, 3] }`), "foo[1]", true, p(`{ "foo": [1, true, 3] }`)},
+		{p(`{ "foo": [{ "bar": 1 }] }`), "foo[0].baz", 2.0, p(`{ "foo": [{ "bar": 1, "baz": 2 }] }`)},
+		{p(`{ "foo": "bar" }`), "foo[0][1]", "x", p(`{ "foo": [[null, "x"]] }`)},
 	}
 
 	for _, test := range tests {
@@ -126,6 +151,35 @@ func TestSet(t *testing.T) {
 	}
 }
 
+func TestNewFromEnv(t *testing.T) {
+	environ := []string{
+		"APP_NAME=web",
+		"APP_DB__HOST_NAME=localhost",
+		"APP_DB__PORT=5432",
+		"APP_DEBUG=true",
+		"APP_=ignored",
+		"APP_A____B=ignored",
+		"OTHER_NAME=ignored",
+		"APPNAME=ignored",
+	}
+	params := NewParamsFromEnv("APP", environ)
+	assert.Equal(t, p(`{
+  "name": "web",
+  "db": {"host_name": "localhost", "port": "5432"},
+  "debug": "true"
+}`), params)
+
+	port, err := params.GetNumber("db.port")
+	assert.NoError(t, err)
+	assert.Equal(t, 5432.0, port)
+	debug, err := params.GetBool("debug")
+	assert.NoError(t, err)
+	assert.True(t, debug)
+
+	// a trailing underscore in the prefix is the same as none
+	assert.Equal(t, params, NewParamsFromEnv("APP_", environ))
+}
+
 func TestMerge(t *testing.T) {
 	tests := []struct {
 		a, b     Params
@@ -6,7 +6,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"path"
 
 	"golang.org/x/text/encoding/unicode"
@@ -104,22 +103,20 @@ func readerByPath(p string) readFunc {
 func (r Sandbox) Read(relPath string, format __std.Format, encoding __std.Encoding, module string) ([]byte, error) {
 	// Special case for reading from stdin
 	if relPath == "" {
-		return read(nil, "", format, encoding)
+		return read(nil, "", r.stdin(), format, encoding)
 	}
 
 	loc, err := r.getReadPath(relPath, module)
 	if err != nil {
 		return nil, err
 	}
-	if r.Recorder != nil {
-		r.Recorder.Record(record.ReadFile, record.Params{
-			"path": loc.Vfs.QualifyPath(loc.Path),
-		})
-	}
-	return read(loc.Vfs, loc.Path, format, encoding)
+	r.recordRead(record.ReadFile, loc)
+	return read(loc.Vfs, loc.Path, nil, format, encoding)
 }
 
-func read(vfs http.FileSystem, p string, format __std.Format, encoding __std.Encoding) ([]byte, error) {
+// read reads the file at p in the filesystem given, or if p is empty,
+// from stdin.
+func read(vfs http.FileSystem, p string, stdin io.Reader, format __std.Format, encoding __std.Encoding) ([]byte, error) {
 	var reader readFunc = readRaw
 
 	if encoding == __std.EncodingJSON {
@@ -141,7 +138,7 @@ func read(vfs http.FileSystem, p string, format __std.Format, encoding __std.Enc
 
 	var in io.Reader
 	if p == "" {
-		in = os.Stdin
+		in = stdin
 	} else {
 		f, err := vfs.Open(p)
 		if err != nil {
@@ -2,6 +2,8 @@ package std
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path"
 	"strings"
 
@@ -29,6 +31,16 @@ type Sandbox struct {
 	Modules ModuleAccesser
 	// For recording each read or write
 	Recorder *record.Recorder
+	// Where reads from stdin come from; if nil, the process's own
+	// stdin is used
+	Stdin io.Reader
+}
+
+func (s Sandbox) stdin() io.Reader {
+	if s.Stdin == nil {
+		return os.Stdin
+	}
+	return s.Stdin
 }
 
 // getReadPath resolves a path and an optional module reference, to a
@@ -91,6 +103,17 @@ func (s Sandbox) getReadPath(p, module string) (vfs.Location, error) {
 	}, nil
 }
 
+// recordRead records an operation reading from the location given,
+// if there's a recorder.
+func (s Sandbox) recordRead(kind record.OperationKind, loc vfs.Location) {
+	if s.Recorder == nil {
+		return
+	}
+	s.Recorder.Record(kind, record.Params{
+		"path": loc.Vfs.QualifyPath(loc.Path),
+	})
+}
+
 // getWritePath verifies the path given and resolves it relative to
 // the output directory.
 func (s Sandbox) getWritePath(p, module string) (string, error) {
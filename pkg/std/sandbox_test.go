@@ -1,10 +1,17 @@
 package std
 
 import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/jkcfg/jk/pkg/__std"
+	"github.com/jkcfg/jk/pkg/record"
 	"github.com/jkcfg/jk/pkg/vfs"
 )
 
@@ -193,3 +200,60 @@ func TestSandbox(t *testing.T) {
 	})
 
 }
+
+func TestSandboxRecordWrite(t *testing.T) {
+	recorder := &record.Recorder{}
+	sb := Sandbox{
+		WriteRoot: "out",
+		Modules:   NewModuleResources(),
+		Recorder:  recorder,
+	}
+
+	sb.RecordWrite("sub/foo.yaml", "")
+	sb.RecordWrite("", "")                  // stdout
+	sb.RecordWrite("../forbidden.yaml", "") // not allowed
+
+	_, outputs := recorder.Files()
+	assert.Equal(t, []string{"out/sub/foo.yaml"}, outputs)
+}
+
+func TestSandboxRecordReads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jk-sandbox")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "foo.json"), []byte(`{}`), 0644))
+
+	recorder := &record.Recorder{}
+	sb := Sandbox{
+		Base:     vfs.Location{Vfs: vfs.User(dir, http.Dir(dir)), Path: "/"},
+		Modules:  NewModuleResources(),
+		Recorder: recorder,
+	}
+
+	_, err = MakeDirectoryListing(sb, "sub", "")
+	assert.NoError(t, err)
+	_, err = MakeFileInfo(sb, "sub/foo.json", "")
+	assert.NoError(t, err)
+	// a file that doesn't exist is still a dependency
+	_, err = MakeFileInfo(sb, "sub/bar.json", "")
+	assert.Error(t, err)
+
+	inputs, _ := recorder.Files()
+	assert.Equal(t, []string{
+		filepath.Join(dir, "sub"),
+		filepath.Join(dir, "sub/foo.json"),
+		filepath.Join(dir, "sub/bar.json"),
+	}, inputs)
+}
+
+func TestSandboxStdin(t *testing.T) {
+	sb := Sandbox{
+		Modules: NewModuleResources(),
+		Stdin:   bytes.NewBufferString("input"),
+	}
+
+	in, err := sb.Read("", __std.FormatRaw, __std.EncodingBytes, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "input", string(in))
+}
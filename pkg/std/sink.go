@@ -0,0 +1,133 @@
+package std
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jkcfg/jk/pkg/__std"
+)
+
+// OutputSink receives what scripts write. Paths are those resolved by
+// the Sandbox (slash-separated, and either relative to the working
+// directory or absolute); the empty path stands for stdout. The data
+// given is the value already encoded in the format given.
+type OutputSink interface {
+	// Exists says whether there is already a file at the path given;
+	// it's used for the overwrite option of std.write.
+	Exists(path string) (bool, error)
+	// Write receives the contents of the file at the path given.
+	Write(path string, format __std.Format, data []byte, mode os.FileMode) error
+}
+
+// HostSink writes files to the host filesystem, and stdout to
+// Stdout, or os.Stdout if that is nil.
+type HostSink struct {
+	Stdout io.Writer
+}
+
+// Exists implements OutputSink.Exists.
+func (h HostSink) Exists(path string) (bool, error) {
+	if path == "" {
+		return false, nil
+	}
+	_, err := os.Stat(filepath.FromSlash(path))
+	switch {
+	case os.IsNotExist(err):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+// Write implements OutputSink.Write.
+func (h HostSink) Write(path string, format __std.Format, data []byte, mode os.FileMode) error {
+	if path == "" {
+		stdout := h.Stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		_, err := stdout.Write(data)
+		return err
+	}
+	path = filepath.FromSlash(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, mode)
+}
+
+// MemorySink keeps what's written in memory.
+type MemorySink struct {
+	stdout bytes.Buffer
+	files  map[string][]byte
+}
+
+// NewMemorySink creates an empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{files: map[string][]byte{}}
+}
+
+// Exists implements OutputSink.Exists.
+func (m *MemorySink) Exists(path string) (bool, error) {
+	_, ok := m.files[path]
+	return ok && path != "", nil
+}
+
+// Write implements OutputSink.Write.
+func (m *MemorySink) Write(path string, format __std.Format, data []byte, mode os.FileMode) error {
+	if path == "" {
+		m.stdout.Write(data)
+		return nil
+	}
+	m.files[path] = append([]byte(nil), data...)
+	return nil
+}
+
+// Stdout returns everything written to stdout.
+func (m *MemorySink) Stdout() []byte {
+	return append([]byte(nil), m.stdout.Bytes()...)
+}
+
+// Paths returns the paths of the files written, in order.
+func (m *MemorySink) Paths() []string {
+	var paths []string
+	for p := range m.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// File returns the contents of the file at the path given, and
+// whether it has been written at all.
+func (m *MemorySink) File(path string) ([]byte, bool) {
+	data, ok := m.files[path]
+	return data, ok
+}
+
+// TeeSink gives what's written to each of its sinks in turn. Whether
+// a file exists is decided by the first sink.
+type TeeSink []OutputSink
+
+// Exists implements OutputSink.Exists.
+func (t TeeSink) Exists(path string) (bool, error) {
+	if len(t) == 0 {
+		return false, nil
+	}
+	return t[0].Exists(path)
+}
+
+// Write implements OutputSink.Write.
+func (t TeeSink) Write(path string, format __std.Format, data []byte, mode os.FileMode) error {
+	for _, sink := range t {
+		if err := sink.Write(path, format, data, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
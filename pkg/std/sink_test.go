@@ -0,0 +1,122 @@
+package std
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/__std"
+)
+
+func TestWriteToSink(t *testing.T) {
+	sink := NewMemorySink()
+	sb := Sandbox{WriteRoot: "out", Modules: NewModuleResources()}
+
+	assert.NoError(t, sb.Write(sink, []byte(`{"a":1}`), "sub/a.yaml", "", writeOpts{}))
+	assert.NoError(t, sb.Write(sink, []byte(`"hello"`), "", "", writeOpts{}))
+	assert.Equal(t, []string{"out/sub/a.yaml"}, sink.Paths())
+	data, ok := sink.File("out/sub/a.yaml")
+	assert.True(t, ok)
+	assert.Equal(t, "a: 1\n", string(data))
+	assert.Equal(t, "hello\n", string(sink.Stdout()))
+
+	// Overwrite options are honoured against what's in the sink
+	assert.NoError(t, sb.Write(sink, []byte(`{"a":2}`), "sub/a.yaml", "", writeOpts{overwrite: __std.OverwriteSkip}))
+	data, _ = sink.File("out/sub/a.yaml")
+	assert.Equal(t, "a: 1\n", string(data))
+	assert.Error(t, sb.Write(sink, []byte(`{"a":2}`), "sub/a.yaml", "", writeOpts{overwrite: __std.OverwriteErr}))
+	assert.NoError(t, sb.Write(sink, []byte(`{"a":2}`), "sub/a.yaml", "", writeOpts{overwrite: __std.OverwriteWrite}))
+	data, _ = sink.File("out/sub/a.yaml")
+	assert.Equal(t, "a: 2\n", string(data))
+}
+
+type formatSink struct {
+	MemorySink
+	formats map[string]__std.Format
+}
+
+func (f *formatSink) Write(path string, format __std.Format, data []byte, mode os.FileMode) error {
+	f.formats[path] = format
+	return nil
+}
+
+func TestSinkFormat(t *testing.T) {
+	sink := &formatSink{formats: map[string]__std.Format{}}
+	value := []byte(`{"a":1}`)
+	for path, opts := range map[string]writeOpts{
+		"a.yml":  {},
+		"a.tf":   {},
+		"a.json": {},
+		"a.txt":  {format: __std.FormatYAML},
+	} {
+		assert.NoError(t, write(sink, value, path, opts))
+	}
+	assert.Equal(t, map[string]__std.Format{
+		"a.yml":  __std.FormatYAML,
+		"a.tf":   __std.FormatHCL,
+		"a.json": __std.FormatJSON,
+		"a.txt":  __std.FormatYAML,
+	}, sink.formats)
+}
+
+func TestHostAndTeeSinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jk-sink")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var stdout bytes.Buffer
+	memory := NewMemorySink()
+	tee := TeeSink{HostSink{Stdout: &stdout}, memory}
+	path := filepath.ToSlash(filepath.Join(dir, "sub", "a.txt"))
+
+	exists, err := tee.Exists(path)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, tee.Write(path, __std.FormatRaw, []byte("file"), 0644))
+	assert.NoError(t, tee.Write("", __std.FormatRaw, []byte("stdout"), 0644))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "sub", "a.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "file", string(data))
+	assert.Equal(t, "stdout", stdout.String())
+	data, _ = memory.File(path)
+	assert.Equal(t, "file", string(data))
+	assert.Equal(t, "stdout", string(memory.Stdout()))
+
+	exists, err = tee.Exists(path)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestVerboseToSink(t *testing.T) {
+	sink := NewMemorySink()
+	std := NewStd(Options{
+		Verbose: true,
+		DryRun:  true,
+		Output:  sink,
+		Sandbox: Sandbox{WriteRoot: "out", Modules: NewModuleResources()},
+	})
+
+	b := flatbuffers.NewBuilder(256)
+	path := b.CreateString("a.json")
+	value := b.CreateByteString([]byte(`{}`))
+	__std.WriteArgsStart(b)
+	__std.WriteArgsAddPath(b, path)
+	__std.WriteArgsAddValue(b, value)
+	args := __std.WriteArgsEnd(b)
+	__std.MessageStart(b)
+	__std.MessageAddArgsType(b, __std.ArgsWriteArgs)
+	__std.MessageAddArgs(b, args)
+	b.Finish(__std.MessageEnd(b))
+
+	// What's done is printed to the sink's stdout, like anything
+	// else printed
+	assert.Nil(t, std.Execute(b.FinishedBytes(), nil))
+	assert.Equal(t, "write a.json\n", string(sink.Stdout()))
+}
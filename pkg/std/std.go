@@ -8,6 +8,7 @@ import (
 	"github.com/jkcfg/jk/pkg/__std"
 	"github.com/jkcfg/jk/pkg/__std/lib"
 	"github.com/jkcfg/jk/pkg/deferred"
+	"github.com/jkcfg/jk/pkg/record"
 	"github.com/jkcfg/jk/pkg/schema"
 
 	flatbuffers "github.com/google/flatbuffers/go"
@@ -44,6 +45,29 @@ type Options struct {
 	// ExtMethods is where extension RPC methods are registered (the
 	// standard ones are here, and take precedence)
 	ExtMethods map[string]RPCFunc
+	// Commands are the commands scripts may run with std.exec. If
+	// empty, no commands may be run.
+	Commands []string
+	// EnvAllow are patterns (as for path.Match) matching the
+	// environment variables scripts may look up with std.env. If
+	// empty, no variables may be looked up.
+	EnvAllow []string
+	// Declarations collects the parameters declared by scripts with
+	// param.declare. If nil, an empty set is used.
+	Declarations *ParamDeclarations
+	// StrictParams makes it an error to supply parameters that
+	// scripts haven't declared.
+	StrictParams bool
+	// SkipParamValidation stops the parameters supplied from being
+	// checked against those declared, which are only collected (as
+	// for listing them).
+	SkipParamValidation bool
+	// Deferreds schedules the actions for deferred values (e.g., for
+	// reads). If nil, the global scheduler is used.
+	Deferreds *deferred.Scheduler
+	// Output receives what's written. If nil, files are written to
+	// the host filesystem, and stdout to os.Stdout.
+	Output OutputSink
 }
 
 // Std represents the standard library.
@@ -53,11 +77,32 @@ type Std struct {
 
 // NewStd creates a new instance of the standard library.
 func NewStd(options Options) *Std {
+	if options.Output == nil {
+		options.Output = HostSink{}
+	}
+	if options.Declarations == nil {
+		options.Declarations = NewParamDeclarations()
+	}
 	return &Std{
 		options: options,
 	}
 }
 
+// register schedules an action for a deferred value, with the
+// scheduler in the options if there is one.
+func (options Options) register(perform func() ([]byte, error), send sendFunc) deferred.Serial {
+	if options.Deferreds != nil {
+		return options.Deferreds.Register(perform, send)
+	}
+	return deferred.Register(perform, send)
+}
+
+// verbosef prints what's being done, when Verbose is set, to stdout
+// as given by the Output sink, as for anything else printed.
+func (options Options) verbosef(format string, args ...interface{}) {
+	options.Output.Write("", __std.FormatRaw, []byte(fmt.Sprintf(format, args...)), 0)
+}
+
 // stdError builds an Error flatbuffer we can return to the javascript side.
 func stdError(b *flatbuffers.Builder, err error) flatbuffers.UOffsetT {
 	off := b.CreateString(err.Error())
@@ -126,10 +171,20 @@ func (std *Std) Execute(msg []byte, res sender) []byte {
 
 		path := string(args.Path())
 		if path != "" && options.Verbose {
-			fmt.Printf("write %s\n", path)
+			options.verbosef("write %s\n", path)
 		}
 
+		module := string(args.Module())
+		if err := checkUnknownParams(options); err != nil {
+			b := flatbuffers.NewBuilder(512)
+			off := stdError(b, err)
+			b.Finish(off)
+			return b.FinishedBytes()
+		}
 		if options.DryRun {
+			// Writes are still recorded, since they are the
+			// targets when emitting dependencies.
+			options.Sandbox.RecordWrite(path, module)
 			break
 		}
 
@@ -138,9 +193,8 @@ func (std *Std) Execute(msg []byte, res sender) []byte {
 			indent:    int(args.Indent()),
 			overwrite: args.Overwrite(),
 		}
-		module := string(args.Module())
 
-		if err := std.options.Sandbox.Write(args.Value(), path, module, opts); err != nil {
+		if err := options.Sandbox.Write(options.Output, args.Value(), path, module, opts); err != nil {
 			b := flatbuffers.NewBuilder(512)
 			off := stdError(b, err)
 			b.Finish(off)
@@ -153,10 +207,10 @@ func (std *Std) Execute(msg []byte, res sender) []byte {
 		args.Init(union.Bytes, union.Pos)
 		path := string(args.Path())
 		if path != "" && options.Verbose {
-			fmt.Printf("read (as %s) %s\n", __std.EnumNamesFormat[args.Format()], path)
+			options.verbosef("read (as %s) %s\n", __std.EnumNamesFormat[args.Format()], path)
 		}
 		module := string(args.Module())
-		ser := deferred.Register(func() ([]byte, error) {
+		ser := options.register(func() ([]byte, error) {
 			return options.Sandbox.Read(path, args.Format(), args.Encoding(), module)
 		}, sendFunc(res.SendBytes))
 		return deferredResponse(ser)
@@ -188,14 +242,31 @@ func (std *Std) Execute(msg []byte, res sender) []byte {
 				if err != nil {
 					return nil, err
 				}
+				options.Sandbox.recordRead(record.SchemaFile, loc)
 				return schema.ValidateWithFile(v, loc.Vfs, loc.Path)
 			})
+		case "std.exec":
+			rpcfn = func(args []interface{}) (interface{}, error) {
+				return execCommand(options, args)
+			}
+		case "std.param.declare":
+			rpcfn = func(args []interface{}) (interface{}, error) {
+				return declareParams(options, args)
+			}
+		case "std.env":
+			rpcfn = func(args []interface{}) (interface{}, error) {
+				return lookupEnv(options, args)
+			}
 		default:
 			rpcfn = options.ExtMethods[method]
 		}
 
 		if rpcfn == nil {
-			return deferredError("RPC method not found: " + method)
+			msg := "RPC method not found: " + method
+			if args.Sync() {
+				return rpcError(msg)
+			}
+			return deferredError(msg)
 		}
 
 		numArgs := args.ArgsLength()
@@ -233,7 +304,7 @@ func (std *Std) Execute(msg []byte, res sender) []byte {
 			}
 			return rpcData(bytes)
 		}
-		ser := deferred.Register(func() ([]byte, error) {
+		ser := options.register(func() ([]byte, error) {
 			result, err := rpcfn(arguments)
 			if err != nil {
 				return nil, err
@@ -265,7 +336,15 @@ func (std *Std) Execute(msg []byte, res sender) []byte {
 			kind byte
 		)
 
-		json, err := param(options.Parameters, __std.ParamType(args.Type()), string(args.Path()), string(args.DefaultValue()))
+		json, err := param(options.Parameters, options.Declarations, __std.ParamType(args.Type()), string(args.Path()), string(args.DefaultValue()))
+		if recorder := options.Sandbox.Recorder; recorder != nil {
+			// a declared default doesn't count as being set
+			_, getErr := options.Parameters.Get(string(args.Path()))
+			recorder.Record(record.Parameter, record.Params{
+				"name": string(args.Path()),
+				"set":  err == nil && getErr == nil && string(json) != "null",
+			})
+		}
 		if err != nil {
 			kind = __std.ParamRetvalError
 			off = stdError(b, err)
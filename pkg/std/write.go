@@ -1,15 +1,15 @@
 package std
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/jkcfg/jk/pkg/__std"
+	"github.com/jkcfg/jk/pkg/record"
 
 	"github.com/ghodss/yaml"
 	yamlclassic "gopkg.in/yaml.v2"
@@ -24,10 +24,6 @@ type writeOpts struct {
 	overwrite __std.Overwrite
 }
 
-type closer func()
-
-func nilCloser() {}
-
 type writerFunc func(io.Writer, []byte, int) error
 
 type writeString bool
@@ -126,24 +122,6 @@ func writeRaw(w io.Writer, value []byte, _ int) error {
 	return err
 }
 
-func writer(path string) (io.Writer, closer) {
-	if path == "" {
-		return os.Stdout, nilCloser
-	}
-
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0770); err != nil {
-		log.Fatal(err)
-	}
-
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return f, func() { f.Close() }
-}
-
 func writerFuncFromPath(path string) writerFunc {
 	ext := filepath.Ext(path)
 	switch ext {
@@ -160,60 +138,95 @@ func writerFuncFromPath(path string) writerFunc {
 	}
 }
 
-func exists(path string) bool {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return false
+// formatFromPath gives the format that a value written to path is
+// encoded in, when the format is determined by the extension.
+func formatFromPath(path string) __std.Format {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		return __std.FormatYAML
+	case ".hcl", ".tf":
+		return __std.FormatHCL
+	default:
+		return __std.FormatJSON
 	}
-	return true
 }
 
-func write(value []byte, path string, opts writeOpts) error {
+// write encodes the value according to opts, and gives it to the
+// sink out, taking care of the overwrite option.
+func write(out OutputSink, value []byte, path string, opts writeOpts) error {
 	switch opts.overwrite {
 	case __std.OverwriteWrite:
 		break
-	case __std.OverwriteSkip:
-		if exists(path) {
-			return nil
+	case __std.OverwriteSkip, __std.OverwriteErr:
+		exists, err := out.Exists(path)
+		if err != nil {
+			return err
 		}
-	case __std.OverwriteErr:
-		if exists(path) {
+		if exists && opts.overwrite == __std.OverwriteErr {
 			return fmt.Errorf("file %s already exists", path)
 		}
+		if exists {
+			return nil
+		}
 	}
 
-	w, close := writer(path)
-
-	var out writerFunc
+	var encode writerFunc
+	format := opts.format
 	switch opts.format {
 	case __std.FormatFromExtension:
-		out = writerFuncFromPath(path)
+		encode = writerFuncFromPath(path)
+		format = formatFromPath(path)
 	case __std.FormatJSON:
-		out = writeJSON(jsonString)
+		encode = writeJSON(jsonString)
 	case __std.FormatJSONStream:
-		out = writeJSONStream
+		encode = writeJSONStream
 	case __std.FormatYAML:
-		out = writeYAML
+		encode = writeYAML
 	case __std.FormatYAMLStream:
-		out = writeYAMLStream
+		encode = writeYAMLStream
 	case __std.FormatHCL:
-		out = writeHCL
+		encode = writeHCL
 	case __std.FormatRaw:
-		out = writeRaw
+		encode = writeRaw
 	default:
 		return fmt.Errorf("write: unknown output format (%d)", int(opts.format))
 	}
 
-	defer close()
-	if err := out(w, value, opts.indent); err != nil {
+	var buf bytes.Buffer
+	if err := encode(&buf, value, opts.indent); err != nil {
 		return err
 	}
-	return nil
+	return out.Write(path, format, buf.Bytes(), 0666)
 }
 
-func (s Sandbox) Write(value []byte, path, module string, opts writeOpts) error {
+// Write writes the value to the path given, relative to the write
+// root or if given, the module identified by `module`. What's
+// written goes to the sink out.
+func (s Sandbox) Write(out OutputSink, value []byte, path, module string, opts writeOpts) error {
 	p, err := s.getWritePath(path, module)
 	if err != nil {
 		return err
 	}
-	return write(value, p, opts)
+	s.recordWrite(p)
+	return write(out, value, p, opts)
+}
+
+// RecordWrite records the write that would be made to the path given,
+// without writing anything. Writes that would not be allowed are not
+// recorded.
+func (s Sandbox) RecordWrite(path, module string) {
+	if p, err := s.getWritePath(path, module); err == nil {
+		s.recordWrite(p)
+	}
+}
+
+// recordWrite records a write to the (resolved) path, unless it's to
+// stdout.
+func (s Sandbox) recordWrite(p string) {
+	if s.Recorder == nil || p == "" {
+		return
+	}
+	s.Recorder.Record(record.WriteFile, record.Params{
+		"path": p,
+	})
 }
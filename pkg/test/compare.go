@@ -0,0 +1,170 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	yamlclassic "gopkg.in/yaml.v2"
+)
+
+// parseValues parses the content of a JSON or YAML file (as
+// determined by the extension of the path given) into a list of
+// values, one for each document or value in a stream. It returns
+// false if the file isn't JSON or YAML, or can't be parsed.
+func parseValues(path string, data []byte) ([]interface{}, bool) {
+	var values []interface{}
+	switch filepath.Ext(path) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			var v interface{}
+			err := dec.Decode(&v)
+			if err == io.EOF {
+				return values, true
+			}
+			if err != nil {
+				return nil, false
+			}
+			values = append(values, v)
+		}
+	case ".yaml", ".yml":
+		dec := yamlclassic.NewDecoder(bytes.NewReader(data))
+		for {
+			var doc interface{}
+			err := dec.Decode(&doc)
+			if err == io.EOF {
+				return values, true
+			}
+			if err != nil {
+				return nil, false
+			}
+			// Go via JSON, so the values are of the same types as
+			// for JSON (e.g., maps have string keys).
+			docYAML, err := yamlclassic.Marshal(doc)
+			if err != nil {
+				return nil, false
+			}
+			docJSON, err := yaml.YAMLToJSON(docYAML)
+			if err != nil {
+				return nil, false
+			}
+			var v interface{}
+			if err := json.Unmarshal(docJSON, &v); err != nil {
+				return nil, false
+			}
+			values = append(values, v)
+		}
+	}
+	return nil, false
+}
+
+// compareSemantically compares the contents of two JSON or YAML files,
+// ignoring formatting and the order of keys. It returns the
+// differences, if there are any; if either can't be parsed, it returns
+// false.
+func compareSemantically(path string, expected, got []byte) ([]string, bool) {
+	expectedValues, ok := parseValues(path, expected)
+	if !ok {
+		return nil, false
+	}
+	gotValues, ok := parseValues(path, got)
+	if !ok {
+		return nil, false
+	}
+
+	if len(expectedValues) != len(gotValues) {
+		return []string{fmt.Sprintf("expected %d documents, got %d", len(expectedValues), len(gotValues))}, true
+	}
+	var diffs []string
+	for i := range expectedValues {
+		root := "$"
+		if len(expectedValues) > 1 {
+			root = fmt.Sprintf("$[document %d]", i)
+		}
+		diffs = append(diffs, diffValues(root, expectedValues[i], gotValues[i])...)
+	}
+	return diffs, true
+}
+
+func showValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// diffValues lists the differences between two values, each of which
+// is the result of decoding JSON, by their path within the values.
+func diffValues(path string, expected, got interface{}) []string {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for k := range e {
+			keys[k] = true
+		}
+		for k := range g {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var diffs []string
+		for _, k := range sorted {
+			ev, inExpected := e[k]
+			gv, inGot := g[k]
+			p := path + "." + k
+			switch {
+			case !inGot:
+				diffs = append(diffs, fmt.Sprintf("%s: missing (expected %s)", p, showValue(ev)))
+			case !inExpected:
+				diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", p, showValue(gv)))
+			default:
+				diffs = append(diffs, diffValues(p, ev, gv)...)
+			}
+		}
+		return diffs
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok {
+			break
+		}
+		var diffs []string
+		for i := 0; i < len(e) || i < len(g); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(g):
+				diffs = append(diffs, fmt.Sprintf("%s: missing (expected %s)", p, showValue(e[i])))
+			case i >= len(e):
+				diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", p, showValue(g[i])))
+			default:
+				diffs = append(diffs, diffValues(p, e[i], g[i])...)
+			}
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(expected, got) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: expected %s, got %s", path, showValue(expected), showValue(got))}
+}
+
+// formatDiffs formats the differences found comparing a file.
+func formatDiffs(file string, diffs []string) string {
+	return fmt.Sprintf("%s differs from what was expected:\n  %s", file, strings.Join(diffs, "\n  "))
+}
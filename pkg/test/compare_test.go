@@ -0,0 +1,45 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareSemantically(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+		got      string
+		diffs    []string
+	}{
+		{"a.json", `{"a": 1, "b": [1, 2]}`, "{\n  \"b\": [1, 2],\n  \"a\": 1\n}\n", nil},
+		{"a.yaml", "a: 1\nb:\n- x\n", `{"b": ["x"], "a": 1}`, nil},
+		{"a.yaml", "a: 1\n---\nb: 2\n", "a: 1\n---\nb: 2\n", nil},
+		{"a.json", `{"a": {"b": 1, "c": 2}}`, `{"a": {"b": 2, "d": 3}}`, []string{
+			`$.a.b: expected 1, got 2`,
+			`$.a.c: missing (expected 2)`,
+			`$.a.d: unexpected 3`,
+		}},
+		{"a.yaml", "l: [1, 2]\n", "l: [1]\n", []string{
+			`$.l[1]: missing (expected 2)`,
+		}},
+		{"a.yaml", "a: 1\n---\nb: 2\n", "a: 1\n---\nb: 3\n", []string{
+			`$[document 1].b: expected 2, got 3`,
+		}},
+		{"a.yaml", "a: 1\n", "a: 1\n---\nb: 2\n", []string{
+			`expected 1 documents, got 2`,
+		}},
+	}
+
+	for _, test := range tests {
+		diffs, ok := compareSemantically(test.path, []byte(test.expected), []byte(test.got))
+		assert.True(t, ok)
+		assert.Equal(t, test.diffs, diffs, test.expected)
+	}
+
+	_, ok := compareSemantically("a.txt", []byte("a"), []byte("a"))
+	assert.False(t, ok, "not JSON or YAML")
+	_, ok = compareSemantically("a.json", []byte("{"), []byte("{}"))
+	assert.False(t, ok, "not valid JSON")
+}
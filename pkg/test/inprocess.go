@@ -0,0 +1,55 @@
+package test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/jkcfg/jk/pkg/jk"
+)
+
+// execInProcess runs the script in a runtime of its own, as `jk run`
+// would run it, keeping what it prints and the files it writes in
+// memory.
+func (test *Test) execInProcess() (*result, error) {
+	script := filepath.Join(test.opts.WorkingDirectory, test.file)
+
+	var stdin bytes.Buffer
+	if exists(test.file + ".in") {
+		in, err := ioutil.ReadFile(test.file + ".in")
+		if err != nil {
+			return nil, err
+		}
+		stdin.Write(in)
+	}
+
+	runtime, err := jk.New(jk.Options{
+		ScriptDirectory: filepath.Dir(script),
+		InputDirectory:  test.opts.InputDirectory,
+		Parameters:      test.opts.Parameters,
+		InMemory:        true,
+		Stdin:           &stdin,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer runtime.Close()
+
+	runErr := runtime.RunFile(script)
+	out, err := runtime.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &result{output: string(out.Stdout), files: map[string][]byte{}}
+	if runErr != nil {
+		// This is what jk would print before exiting.
+		res.output += runErr.Error() + "\n"
+	}
+	// Files are keyed as find gives them, i.e., with a leading
+	// separator.
+	for p, data := range out.Files {
+		res.files[string(filepath.Separator)+filepath.FromSlash(p)] = data
+	}
+	return res, runErr
+}
@@ -7,10 +7,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/jkcfg/jk/pkg/std"
 )
 
 func isDir(name string) bool {
@@ -85,6 +88,37 @@ type Options struct {
 	// the expected generated names should be named. It defaults to:
 	//   echo $(echo $script | cut -f 1 -d '.').expected
 	ExpectedOutputDirectory Namer
+
+	// InProcess runs the script in a VM in the test process, as `jk
+	// run` would, rather than running a jk binary found in $PATH.
+	// What the script prints and the files it writes are kept in
+	// memory. Tests with a command file still run the commands in it.
+	InProcess bool
+
+	// Parameters are the parameters given to the script when it is
+	// run in process. (A command file can give them on the command
+	// line otherwise.)
+	Parameters std.Params
+
+	// InputDirectory is where the script reads files from, when run
+	// in process. It defaults to the directory containing the script.
+	InputDirectory string
+
+	// Parallel runs the test in parallel with other tests that are
+	// also run in parallel; see testing.T.Parallel. Tests run with a
+	// jk binary must not share an output directory to be run in
+	// parallel.
+	Parallel bool
+
+	// Update makes the test rewrite its expected output (stdout and
+	// files) with what it produces, rather than comparing it. A test
+	// package will usually set this from a flag, e.g., -update.
+	Update bool
+
+	// Semantic makes JSON and YAML output files compare by value,
+	// ignoring formatting and the order of keys. It can also be
+	// turned on for a test by creating a $script.semantic file.
+	Semantic bool
 }
 
 // Test is a end to end test, corresponding to one test-$testname.js file.
@@ -132,6 +166,10 @@ func (test *Test) shouldErrorOut() bool {
 	return exists(test.file + ".error")
 }
 
+func (test *Test) compareSemantically() bool {
+	return test.opts.Semantic || exists(test.file+".semantic")
+}
+
 func (test *Test) shouldSkip() bool {
 	return exists(test.file + ".skip")
 }
@@ -271,11 +309,59 @@ func (test *Test) execDefault() (string, error) {
 	return string(output), err
 }
 
-func (test *Test) exec() (string, error) {
-	if exists(test.commandFile()) {
-		return test.execWithCmd()
+// result is what running a test produced: its output (stdout and
+// stderr), and the files it wrote, keyed by their path relative to the
+// output directory.
+type result struct {
+	output string
+	files  map[string][]byte
+}
+
+// paths returns the paths of the files written, in order.
+func (r *result) paths() []string {
+	var paths []string
+	for p := range r.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func readFiles(dir string) (map[string][]byte, error) {
+	paths, err := find(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, p := range paths {
+		data, err := ioutil.ReadFile(dir + p)
+		if err != nil {
+			return nil, err
+		}
+		files[p] = data
+	}
+	return files, nil
+}
+
+func (test *Test) exec() (*result, error) {
+	var (
+		output string
+		err    error
+	)
+	switch {
+	case exists(test.commandFile()):
+		output, err = test.execWithCmd()
+	case test.opts.InProcess:
+		return test.execInProcess()
+	default:
+		output, err = test.execDefault()
 	}
-	return test.execDefault()
+
+	files, readErr := readFiles(test.outputDirectory())
+	if readErr != nil && err == nil {
+		err = readErr
+	}
+	return &result{output: output, files: files}, err
 }
 
 // Run executes the test and compare its output to the expected state.
@@ -284,29 +370,41 @@ func (test *Test) Run(t *testing.T) {
 		return
 	}
 
-	output, err := test.exec()
+	if test.opts.Parallel {
+		t.Parallel()
+	}
+
+	got, err := test.exec()
+	if got == nil {
+		assert.FailNow(t, err.Error())
+	}
 
 	// 0. Check process exit code.
 	if test.shouldErrorOut() {
 		assert.Error(t, err)
-		if err != nil {
+		if err != nil && !test.opts.InProcess {
 			_, ok := err.(*exec.ExitError)
 			assert.True(t, ok, err.Error())
 		}
 	} else {
 		if err != nil {
-			fmt.Print(string(output))
+			fmt.Print(got.output)
 		}
 		assert.NoError(t, err)
 	}
 
+	if test.opts.Update {
+		assert.NoError(t, test.updateExpected(got))
+		return
+	}
+
 	// 1. Compare stdout/err.
 	expected, _ := ioutil.ReadFile(test.expectedOutputFile())
-	assert.Equal(t, string(expected), string(output))
+	assert.Equal(t, string(expected), got.output)
 
 	// 2. Compare produced files.
 	expectedFiles, _ := find(test.expectedOutputDirectory())
-	gotFiles, _ := find(test.outputDirectory())
+	gotFiles := got.paths()
 
 	// 2. a) Compare the list of files.
 	if !assert.Equal(t, expectedFiles, gotFiles) {
@@ -317,9 +415,45 @@ func (test *Test) Run(t *testing.T) {
 	for i := range expectedFiles {
 		expected, err := ioutil.ReadFile(test.expectedOutputDirectory() + expectedFiles[i])
 		assert.NoError(t, err)
-		got, err := ioutil.ReadFile(test.outputDirectory() + gotFiles[i])
-		assert.NoError(t, err)
-
+		got := got.files[gotFiles[i]]
+
+		if test.compareSemantically() {
+			if diffs, ok := compareSemantically(gotFiles[i], expected, got); ok {
+				if len(diffs) > 0 {
+					assert.Fail(t, formatDiffs(gotFiles[i], diffs))
+				}
+				continue
+			}
+		}
 		assert.Equal(t, string(expected), string(got))
 	}
 }
+
+// updateExpected rewrites the expected output of the test with the
+// output and files in the result given. An absent expected output file
+// or directory is the same as an empty one, so these are removed rather
+// than being left empty.
+func (test *Test) updateExpected(got *result) error {
+	expectedFile := test.expectedOutputFile()
+	if got.output == "" {
+		if err := os.Remove(expectedFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := ioutil.WriteFile(expectedFile, []byte(got.output), 0644); err != nil {
+		return err
+	}
+
+	expectedDir := test.expectedOutputDirectory()
+	if err := os.RemoveAll(expectedDir); err != nil {
+		return err
+	}
+	for _, f := range got.paths() {
+		if err := os.MkdirAll(filepath.Dir(expectedDir+f), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(expectedDir+f, got.files[f], 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package testrun
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteTAP writes the results of the suites given in the Test
+// Anything Protocol (version 13) format.
+func WriteTAP(w io.Writer, suites []*Suite) error {
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	n := 0
+	for _, suite := range suites {
+		for _, r := range suite.Results {
+			n++
+			status := "ok"
+			if !r.Pass {
+				status = "not ok"
+			}
+			fmt.Fprintf(&b, "%s %d - %s: %s\n", status, n, suite.File, tapEscape(r.Name))
+			if !r.Pass {
+				writeTAPDiagnostic(&b, r.Error)
+			}
+		}
+		if suite.Err != nil {
+			n++
+			fmt.Fprintf(&b, "not ok %d - %s\n", n, suite.File)
+			writeTAPDiagnostic(&b, suite.Err.Error())
+		}
+	}
+	fmt.Fprintf(&b, "1..%d\n", n)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tapEscape escapes characters that have a meaning in a test
+// description.
+func tapEscape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "#", "\\#", "\n", " ").Replace(s)
+}
+
+func writeTAPDiagnostic(b *strings.Builder, msg string) {
+	b.WriteString("  ---\n  message: |\n")
+	for _, line := range strings.Split(strings.TrimRight(msg, "\n"), "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+	b.WriteString("  ...\n")
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+	Error    *junitFailure   `xml:"error,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// firstLine is used for the message attribute of failures, since
+// the whole error (e.g., with a stack trace) is the text.
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// WriteJUnit writes the results of the suites given as JUnit XML,
+// with a testsuite for each file.
+func WriteJUnit(w io.Writer, suites []*Suite) error {
+	var out junitTestSuites
+	var total time.Duration
+	for _, suite := range suites {
+		js := junitTestSuite{Name: suite.File}
+		var suiteTime time.Duration
+		for _, r := range suite.Results {
+			tc := junitTestCase{Name: r.Name, ClassName: suite.File, Time: seconds(r.Duration)}
+			if !r.Pass {
+				tc.Failure = &junitFailure{Message: firstLine(r.Error), Text: r.Error}
+				js.Failures++
+			}
+			js.Cases = append(js.Cases, tc)
+			suiteTime += r.Duration
+		}
+		if suite.Err != nil {
+			js.Error = &junitFailure{Message: firstLine(suite.Err.Error()), Text: suite.Err.Error()}
+			js.Errors++
+		}
+		js.Tests = len(suite.Results)
+		js.Time = seconds(suiteTime)
+		total += suiteTime
+
+		out.Tests += js.Tests
+		out.Failures += js.Failures
+		out.Errors += js.Errors
+		out.Suites = append(out.Suites, js)
+	}
+	out.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
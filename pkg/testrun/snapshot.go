@@ -0,0 +1,118 @@
+package testrun
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// SnapshotDir is the name of the directory, alongside a test file,
+// in which its snapshots are kept.
+const SnapshotDir = "__snapshots__"
+
+// SnapshotPath returns the path of the file holding the snapshots for
+// the test file given.
+func SnapshotPath(testFile string) string {
+	return filepath.Join(filepath.Dir(testFile), SnapshotDir, filepath.Base(testFile)+".snap.json")
+}
+
+// Snapshots is the set of snapshots for a test file.
+type Snapshots struct {
+	path   string
+	update bool
+
+	stored  map[string]interface{}
+	checked map[string]interface{}
+	changed bool
+}
+
+// LoadSnapshots reads the snapshots from the file given, if it
+// exists. If update is true, snapshots that don't match are replaced
+// rather than failing.
+func LoadSnapshots(path string, update bool) (*Snapshots, error) {
+	s := &Snapshots{
+		path:    path,
+		update:  update,
+		stored:  map[string]interface{}{},
+		checked: map[string]interface{}{},
+	}
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.stored); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// normalise gives the value as it would be after a round trip through
+// JSON, so that values from JavaScript and values read from a file
+// can be compared.
+func normalise(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+// Check compares the value given with the snapshot named. If there's
+// no such snapshot, it's recorded and the check passes. It returns
+// the value expected, i.e., the snapshot as it was.
+func (s *Snapshots) Check(name string, value interface{}) (bool, interface{}, error) {
+	value, err := normalise(value)
+	if err != nil {
+		return false, nil, err
+	}
+	s.checked[name] = value
+
+	expected, ok := s.stored[name]
+	switch {
+	case !ok:
+		s.stored[name] = value
+		s.changed = true
+		return true, nil, nil
+	case reflect.DeepEqual(expected, value):
+		return true, expected, nil
+	case s.update:
+		s.stored[name] = value
+		s.changed = true
+		return true, expected, nil
+	}
+	return false, expected, nil
+}
+
+// Save writes the snapshots back to the file, if they have changed.
+// When updating, and prune is true (i.e., all the tests have run),
+// snapshots that weren't checked are dropped.
+func (s *Snapshots) Save(prune bool) error {
+	if s.update && prune && len(s.checked) != len(s.stored) {
+		s.stored, s.changed = s.checked, true
+	}
+	if !s.changed {
+		return nil
+	}
+	if len(s.stored) == 0 {
+		err := os.Remove(s.path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(s.stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,93 @@
+// Package testrun has the machinery for `jk test`: finding test
+// files, keeping snapshots, and reporting results.
+package testrun
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestFileSuffixes are the suffixes of files that are treated as tests.
+var TestFileSuffixes = []string{".test.js", ".test.ts"}
+
+// Result is the outcome of a single test case.
+type Result struct {
+	Name     string
+	Pass     bool
+	Error    string
+	Duration time.Duration
+}
+
+// Suite is the outcome of running the tests in one file.
+type Suite struct {
+	File    string
+	Results []Result
+	// Err is set if the file could not be run to completion, e.g.,
+	// because it could not be loaded.
+	Err error
+}
+
+// Failed says whether any test in the suite failed, or the suite
+// itself failed.
+func (s *Suite) Failed() bool {
+	if s.Err != nil {
+		return true
+	}
+	for _, r := range s.Results {
+		if !r.Pass {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTestFile says whether the path given is of a test file.
+func IsTestFile(path string) bool {
+	for _, suffix := range TestFileSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Discover finds the test files at the paths given. Directories are
+// walked, skipping node_modules and hidden directories; files are
+// included as they are, whatever they are called.
+func Discover(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				name := info.Name()
+				if path != p && (name == "node_modules" || strings.HasPrefix(name, ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if IsTestFile(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
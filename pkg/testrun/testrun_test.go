@@ -0,0 +1,139 @@
+package testrun
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jk-testrun")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, f := range []string{
+		"a.test.js",
+		"b.js",
+		"sub/c.test.ts",
+		"node_modules/lib/d.test.js",
+		".git/e.test.js",
+	} {
+		p := filepath.Join(dir, f)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		assert.NoError(t, ioutil.WriteFile(p, nil, 0644))
+	}
+
+	files, err := Discover([]string{dir, filepath.Join(dir, "b.js")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.test.js"),
+		filepath.Join(dir, "b.js"),
+		filepath.Join(dir, "sub/c.test.ts"),
+	}, files)
+}
+
+func TestSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jk-testrun")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := SnapshotPath(filepath.Join(dir, "config.test.js"))
+	assert.Equal(t, filepath.Join(dir, "__snapshots__", "config.test.js.snap.json"), path)
+
+	value := map[string]interface{}{"replicas": 3}
+
+	// New snapshots are recorded
+	s, err := LoadSnapshots(path, false)
+	assert.NoError(t, err)
+	pass, _, err := s.Check("deployment 1", value)
+	assert.NoError(t, err)
+	assert.True(t, pass)
+	s.Check("obsolete", 1)
+	assert.NoError(t, s.Save(true))
+
+	// and compared with next time
+	s, err = LoadSnapshots(path, false)
+	assert.NoError(t, err)
+	pass, _, _ = s.Check("deployment 1", map[string]interface{}{"replicas": 3.0})
+	assert.True(t, pass)
+	pass, expected, _ := s.Check("deployment 1", map[string]interface{}{"replicas": 4})
+	assert.False(t, pass)
+	assert.Equal(t, map[string]interface{}{"replicas": 3.0}, expected)
+
+	// unless updating, in which case they are replaced, and those
+	// not checked are removed.
+	s, err = LoadSnapshots(path, true)
+	assert.NoError(t, err)
+	pass, _, _ = s.Check("deployment 1", map[string]interface{}{"replicas": 4})
+	assert.True(t, pass)
+	assert.NoError(t, s.Save(true))
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "deployment 1": {
+    "replicas": 4
+  }
+}
+`, string(data))
+}
+
+func testSuites() []*Suite {
+	return []*Suite{
+		{
+			File: "a.test.js",
+			Results: []Result{
+				{Name: "passes", Pass: true, Duration: 2 * time.Millisecond},
+				{Name: "fails #1", Pass: false, Error: "AssertionError: expected 1, got 2\n    at a.test.js:3:3", Duration: time.Millisecond},
+			},
+		},
+		{
+			File: "b.test.js",
+			Err:  errors.New("b.test.js:1\nsyntax error"),
+		},
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteTAP(&buf, testSuites()))
+	assert.Equal(t, `TAP version 13
+ok 1 - a.test.js: passes
+not ok 2 - a.test.js: fails \#1
+  ---
+  message: |
+    AssertionError: expected 1, got 2
+        at a.test.js:3:3
+  ...
+not ok 3 - b.test.js
+  ---
+  message: |
+    b.test.js:1
+    syntax error
+  ...
+1..3
+`, buf.String())
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteJUnit(&buf, testSuites()))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="2" failures="1" errors="1" time="0.003">
+  <testsuite name="a.test.js" tests="2" failures="1" errors="0" time="0.003">
+    <testcase name="passes" classname="a.test.js" time="0.002"></testcase>
+    <testcase name="fails #1" classname="a.test.js" time="0.001">
+      <failure message="AssertionError: expected 1, got 2">AssertionError: expected 1, got 2&#xA;    at a.test.js:3:3</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="b.test.js" tests="0" failures="0" errors="1" time="0.000">
+    <error message="b.test.js:1">b.test.js:1&#xA;syntax error</error>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/jkcfg/jk/pkg/plugin"
+	"github.com/jkcfg/jk/pkg/record"
+	"github.com/jkcfg/jk/pkg/std"
+)
+
+// pluginSpec is a plugin given on the command line, as name=path.
+type pluginSpec struct {
+	name, path string
+}
+
+// pluginsOption implements a pflag.Value for plugins.
+type pluginsOption struct {
+	plugins *[]pluginSpec
+}
+
+func plugins(opts *vmOptions) pflag.Value {
+	return &pluginsOption{plugins: &opts.plugins}
+}
+
+func (p *pluginsOption) String() string {
+	return ""
+}
+
+func (p *pluginsOption) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected name=path, got %q", s)
+	}
+	if strings.Contains(parts[0], ".") {
+		return fmt.Errorf("plugin name %q may not contain '.'", parts[0])
+	}
+	*p.plugins = append(*p.plugins, pluginSpec{name: parts[0], path: parts[1]})
+	return nil
+}
+
+func (p *pluginsOption) Type() string {
+	return "name=path"
+}
+
+// startedPlugins are the plugins started so far, by name; they are
+// shared by all the VMs in the process (e.g., those created by `jk
+// test`), and exit along with it.
+var startedPlugins = map[string]*plugin.Plugin{}
+
+// pluginMethods starts the plugins given, if they are not already
+// started, and returns their methods as RPC methods, named
+// plugin.<name>.<method>. Each call is recorded in the recorder
+// given, if it's not nil.
+func pluginMethods(specs []pluginSpec, timeout time.Duration, recorder *record.Recorder) (map[string]std.RPCFunc, error) {
+	methods := map[string]std.RPCFunc{}
+	for _, spec := range specs {
+		p, ok := startedPlugins[spec.name]
+		if !ok {
+			var err error
+			if p, err = plugin.Start(spec.name, spec.path, timeout); err != nil {
+				return nil, err
+			}
+			startedPlugins[spec.name] = p
+		}
+		name := spec.name
+		for _, method := range p.Methods() {
+			method := method
+			methods["plugin."+name+"."+method] = func(args []interface{}) (interface{}, error) {
+				if recorder != nil {
+					recorder.Record(record.Plugin, record.Params{
+						"plugin": name,
+						"method": method,
+					})
+				}
+				return p.Call(method, args)
+			}
+		}
+	}
+	return methods, nil
+}
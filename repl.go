@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+)
+
+var replCmd = &cobra.Command{
+	Use:     "repl",
+	Example: replExamples,
+	Short:   "Evaluate JavaScript interactively, with the standard library loaded",
+	Args:    cobra.NoArgs,
+	Run:     repl,
+}
+
+const replExamples = `
+  exploring a file, with parameters set
+    jk repl -p env=prod
+    > std.read('config.yaml').then(cfg => cfg.replicas)
+    > param.String('env')
+`
+
+// ReplSpecifier is the module specifier used for the module that sets
+// up the REPL's global environment.
+const ReplSpecifier = "<repl>"
+
+// replPrelude is loaded as a module once, when the REPL starts. It
+// makes the standard library available as globals, and provides the
+// functions used to evaluate and show each input.
+const replPrelude = `
+import * as std from '@jkcfg/std';
+import * as param from '@jkcfg/std/param';
+import * as fs from '@jkcfg/std/fs';
+
+const globals = (0, eval)('this');
+Object.assign(globals, { std, param, fs, print: std.print, log: std.log });
+
+function show(value) {
+  switch (typeof value) {
+  case 'undefined':
+    return;
+  case 'function':
+    std.print(` + "`[Function ${value.name || '(anonymous)'}]`" + `);
+    return;
+  case 'symbol':
+  case 'bigint':
+    std.print(String(value));
+    return;
+  }
+  if (value !== null && typeof value.then === 'function') {
+    value.then(show, (err) => std.log(` + "`Uncaught (in promise) ${(err && err.stack) || err}`" + `));
+    return;
+  }
+  std.print(value);
+}
+
+globals.__jkrepl = {
+  evaluate(src) {
+    show((0, eval)(src));
+  },
+  loaded(name, module) {
+    Object.assign(globals, module);
+    const names = Object.keys(module).filter(n => n !== 'default');
+    if ('default' in module) {
+      names.push('default (as $default)');
+      globals.$default = module.default;
+    }
+    std.print(` + "`loaded ${name}: ${names.join(', ') || 'no exports'}`" + `);
+  },
+};
+`
+
+// replDeclaration lists the beginnings of input that declares
+// variables or classes in the global lexical scope. These must be run as scripts in their own
+// right, since declarations made in eval'd code don't outlive it.
+var replDeclaration = []string{"let ", "const ", "class "}
+
+const replHelp = `.load <file>  import a module, and make its exports global
+.help         print this help
+.exit         exit the REPL (or press Ctrl-D)
+
+The standard library is available as std, param and fs. If an
+expression evaluates to a promise, its value is printed when it
+resolves.
+`
+
+var replOptions struct {
+	vmOptions
+}
+
+func init() {
+	initAllVMFlags(replCmd, &replOptions.vmOptions)
+	jk.AddCommand(replCmd)
+}
+
+func repl(cmd *cobra.Command, args []string) {
+	scriptDir, err := filepath.Abs(".")
+	if err != nil {
+		log.Fatal(err)
+	}
+	applyProjectConfig(cmd, &replOptions.vmOptions, scriptDir)
+	vm := newVM(&replOptions.vmOptions, scriptDir)
+	if err := vm.Run(ReplSpecifier, replPrelude); err != nil {
+		log.Fatal(err)
+	}
+
+	var line replInput
+	if interactive() {
+		terminal := newReplTerminal(filepath.Join(vm.cacheDir, "repl_history"))
+		defer terminal.Close()
+		line = terminal
+	} else {
+		line = &replPipe{bufio.NewReader(os.Stdin)}
+	}
+
+	var (
+		pending strings.Builder // input so far, if it's incomplete
+		loads   int
+	)
+	for {
+		prompt := "> "
+		if pending.Len() > 0 {
+			prompt = "... "
+		}
+		input, err := line.Prompt(prompt)
+		if err == liner.ErrPromptAborted {
+			pending.Reset()
+			continue
+		}
+		if err == io.EOF {
+			if interactive() {
+				fmt.Println()
+			}
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if pending.Len() == 0 {
+			trimmed := strings.TrimSpace(input)
+			switch {
+			case trimmed == "":
+				continue
+			case trimmed == ".exit":
+				return
+			case trimmed == ".help":
+				fmt.Print(replHelp)
+				continue
+			case strings.HasPrefix(trimmed, ".load "):
+				line.AppendHistory(trimmed)
+				loads++
+				if err := vm.replLoad(strings.TrimSpace(trimmed[len(".load "):]), loads); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+				continue
+			}
+		} else {
+			pending.WriteString("\n")
+		}
+		pending.WriteString(input)
+
+		src := pending.String()
+		err = vm.replEvaluate(src)
+		if err != nil && replIncomplete(err) {
+			continue
+		}
+		line.AppendHistory(src)
+		pending.Reset()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, strings.TrimRight(err.Error(), "\n"))
+		}
+	}
+}
+
+// replEvaluate runs the input given in the global scope, and prints
+// its value (or, if it's a promise, the value it resolves to).
+func (vm *vm) replEvaluate(src string) error {
+	// Load lets any outstanding requests (e.g., reads) complete, so
+	// their results are printed before the next prompt.
+	if isReplDeclaration(src) {
+		return vm.runtime.Load("<repl input>", src)
+	}
+	quoted, _ := json.Marshal(src)
+	return vm.runtime.Load("<repl input>", fmt.Sprintf("__jkrepl.evaluate(%s);", quoted))
+}
+
+// replLoad imports the module at the path given, and makes its
+// exports global.
+func (vm *vm) replLoad(path string, n int) error {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(vm.scriptDir, path)
+		if err != nil {
+			return err
+		}
+		path = rel
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+		path = "./" + path
+	}
+	specifier, _ := json.Marshal(path)
+	source := fmt.Sprintf("import * as m from %s;\n__jkrepl.loaded(%s, m);\n", specifier, specifier)
+	return vm.runtime.Run(fmt.Sprintf("<repl load %d>", n), source)
+}
+
+func isReplDeclaration(src string) bool {
+	trimmed := strings.TrimSpace(src)
+	for _, prefix := range replDeclaration {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// replIncomplete says whether an error means the input so far is
+// incomplete (e.g., a function body without its closing brace), so
+// more lines should be read.
+func replIncomplete(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "SyntaxError: Unexpected end of input") ||
+		strings.Contains(msg, "SyntaxError: Unterminated template literal")
+}
+
+// replInput is where the REPL gets its input from: a terminal, with
+// line editing and history, or a pipe.
+type replInput interface {
+	Prompt(prompt string) (string, error)
+	AppendHistory(item string)
+}
+
+func interactive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+type replTerminal struct {
+	*liner.State
+	historyFile string
+}
+
+func newReplTerminal(historyFile string) *replTerminal {
+	line := liner.NewLiner()
+	line.SetCtrlCAborts(true)
+	line.SetMultiLineMode(true)
+	if f, err := os.Open(historyFile); err == nil {
+		line.ReadHistory(f)
+		f.Close()
+	}
+	return &replTerminal{State: line, historyFile: historyFile}
+}
+
+// Close saves the history and restores the terminal.
+func (t *replTerminal) Close() error {
+	if err := os.MkdirAll(filepath.Dir(t.historyFile), 0755); err == nil {
+		if f, err := os.Create(t.historyFile); err == nil {
+			t.WriteHistory(f)
+			f.Close()
+		}
+	}
+	return t.State.Close()
+}
+
+// replPipe reads input line by line, without prompting, so the REPL
+// can be scripted.
+type replPipe struct {
+	r *bufio.Reader
+}
+
+func (p *replPipe) Prompt(string) (string, error) {
+	line, err := p.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (p *replPipe) AppendHistory(string) {}
@@ -91,7 +91,7 @@ func establishScriptDir(opts scriptOptions, scriptArg string) string {
 	// directory relative to which modules will be resolved.
 	var err error
 	switch {
-	case opts.module && runOptions.inline:
+	case opts.module && opts.inline:
 		log.Fatal("supply one or neither of --module,-m and --exec,-c")
 	case opts.module || opts.inline || scriptArg == "-":
 		scriptDir, err = filepath.Abs(".")
@@ -109,26 +109,34 @@ func establishScriptDir(opts scriptOptions, scriptArg string) string {
 
 func run(cmd *cobra.Command, args []string) {
 	scriptDir := establishScriptDir(runOptions.scriptOptions, args[0])
-	vm := newVM(&runOptions.vmOptions, scriptDir)
-
-	var runErr error
-
-	switch {
-	case runOptions.module:
-		runErr = vm.RunModule(args[0], ToplevelReferrer)
-	case runOptions.inline:
-		runErr = vm.Run(InlineSpecifier, fmt.Sprintf(inlineTemplate, args[0]))
-	case args[0] == "-":
-		input, err := ioutil.ReadAll(os.Stdin)
-		if err != nil {
+	applyProjectConfig(cmd, &runOptions.vmOptions, scriptDir)
+
+	// A script given on stdin is read here, since it may be run
+	// twice (see checkDeclaredParams).
+	var input []byte
+	if !runOptions.module && !runOptions.inline && args[0] == "-" {
+		var err error
+		if input, err = ioutil.ReadAll(os.Stdin); err != nil {
 			log.Fatal(err)
 		}
-		runErr = vm.Run(StdinSpecifier, string(input))
-	default: // a file
-		runErr = vm.RunFile(args[0])
 	}
 
-	if runErr != nil {
-		log.Fatal(runErr)
+	runScript := func(vm *vm) error {
+		switch {
+		case runOptions.module:
+			return vm.RunModule(args[0], ToplevelReferrer)
+		case runOptions.inline:
+			return vm.Run(InlineSpecifier, fmt.Sprintf(inlineTemplate, args[0]))
+		case args[0] == "-":
+			return vm.Run(StdinSpecifier, string(input))
+		default: // a file
+			return vm.RunFile(args[0])
+		}
+	}
+
+	checkDeclaredParams(runOptions.vmOptions, scriptDir, runScript)
+	vm := newVM(&runOptions.vmOptions, scriptDir)
+	if err := runScript(vm); err != nil {
+		log.Fatal(err)
 	}
 }
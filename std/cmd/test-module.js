@@ -0,0 +1,4 @@
+import { run } from '@jkcfg/std/test';
+import '%s';
+
+run();
@@ -25,9 +25,9 @@ function transform(fn: TransformFn): void {
     return txObj;
   }
 
-  const inputFiles = param.Object('jk.transform.input', {});
+  const inputFiles = param.Array<string>('jk.transform.input', []);
   const outputs = [];
-  for (const path of Object.keys(inputFiles)) {
+  for (const path of inputFiles) {
     const format = valuesFormatFromPath(path);
     outputs.push(host.read(path, { format }).then((obj): File => {
       switch (format) {
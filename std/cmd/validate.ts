@@ -20,8 +20,7 @@ function reduce(results: ValidationResult[]): ValidationResult {
 }
 
 export default function validate(fn: ValidateFn): void {
-  const inputFiles = param.Object('jk.validate.input', {});
-  const files = Object.keys(inputFiles);
+  const files = param.Array<string>('jk.validate.input', []);
 
   function validateValue(v: any): Promise<ValidationResult> {
     return Promise.resolve(fn(v)).then(normaliseResult);
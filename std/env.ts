@@ -0,0 +1,18 @@
+/**
+ * @module std/env
+ */
+
+import { RPCSync } from './internal/rpc';
+import { valueFromUTF8Bytes } from './internal/data';
+
+/**
+ * env returns the value of the environment variable named, or
+ * `undefined` if it is not set. Only the variables matching a
+ * pattern given with `--env-allow` (or `envAllow` in the project
+ * configuration) can be looked up. The names looked up are recorded
+ * as dependencies of the script.
+ */
+export function env(name: string): string | undefined {
+  const value = valueFromUTF8Bytes(RPCSync('std.env', name));
+  return (value === null) ? undefined : value;
+}
@@ -0,0 +1,35 @@
+/**
+ * @module std/exec
+ */
+
+import { RPC } from './internal/rpc';
+import { valueFromUTF8Bytes } from './internal/data';
+
+export interface ExecOptions {
+  /** the arguments to give the command */
+  args?: string[];
+  /** environment entries to add to those jk was run with */
+  env?: { [name: string]: string };
+  /** what to give the command on its stdin */
+  stdin?: string;
+}
+
+export interface ExecResult {
+  stdout: string;
+  stderr: string;
+  /** the exit code of the command */
+  code: number;
+}
+
+/**
+ * exec runs a command on the host, and returns a promise of its
+ * output and exit code. Only the commands allowed with `--allow-exec`
+ * (or `allowExec` in the project configuration) can be run; and no
+ * commands are run when emitting dependencies, or listing parameters
+ * with `jk params`. A command exiting
+ * with a non-zero code does not reject the promise; check `code`.
+ */
+export function exec(command: string, options: ExecOptions = {}): Promise<ExecResult> {
+  const { args = [], env = {}, stdin = '' } = options;
+  return RPC('std.exec', command, args, env, stdin).then(valueFromUTF8Bytes);
+}
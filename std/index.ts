@@ -8,3 +8,5 @@ export {
 } from './write';
 export { Encoding, read, stdin } from './read';
 export { parse, stringify } from './parse';
+export { exec } from './exec';
+export { env } from './env';
@@ -5,6 +5,7 @@
 import { flatbuffers } from './internal/flatbuffers';
 import { __std } from './internal/__std_generated';
 import { sendRequest } from './internal/deferred';
+import { RPCSync } from './internal/rpc';
 
 import ParamType = __std.ParamType;
 
@@ -74,6 +75,38 @@ export function Object(path: string, defaultValue?: object): object | undefined
   return getParameter(__std.ParamType.Object, path, defaultValue);
 }
 
+export function Array<T = any>(path: string, defaultValue?: T[]): T[] | undefined {
+  return getParameter(__std.ParamType.Array, path, defaultValue);
+}
+
+export interface Declaration {
+  type: 'boolean' | 'number' | 'string' | 'object' | 'array';
+  description?: string;
+  /** the value used when the parameter is not supplied */
+  default?: any;
+  /** whether the parameter must be supplied */
+  required?: boolean;
+  /** a JSON Schema the value must satisfy */
+  schema?: object;
+}
+
+/**
+ * declare declares the parameters a script (or module) accepts, by
+ * path. The parameters supplied for those declared are checked
+ * against the declarations, and it's an error if any are invalid.
+ * `jk run` and `jk generate` check them before running the script,
+ * having first run it without writing anything (as `jk params` does)
+ * to find what it declares. They are also checked when declare is
+ * called, which is all there is when commands are allowed with
+ * `--allow-exec`; so, declare parameters before using them. With
+ * `--strict-params`, it's an error to supply parameters that aren't
+ * declared. The parameters declared by a script can be listed with
+ * `jk params`.
+ */
+export function declare(declarations: { [path: string]: Declaration }): void {
+  RPCSync('std.param.declare', declarations);
+}
+
 export function all(): object {
   return <object>Object('');
 }
@@ -84,4 +117,6 @@ export const param = {
   Number,
   String,
   Object,
+  Array,
+  declare,
 };
@@ -0,0 +1,25 @@
+/**
+ * @module std/plugin
+ *
+ * plugin has procedures for calling the methods of plugins, which are
+ * programs given to jk with `--plugin <name>=<path>`.
+ */
+
+import { valueFromUTF8Bytes } from './internal/data';
+import { RPC, RPCSync } from './internal/rpc';
+
+/**
+ * call calls a method of the plugin named, with the arguments
+ * given, and returns a promise of the result.
+ */
+export function call(plugin: string, method: string, ...args: any[]): Promise<any> {
+  return RPC(`plugin.${plugin}.${method}`, ...args).then(valueFromUTF8Bytes);
+}
+
+/**
+ * callSync calls a method of the plugin named, with the arguments
+ * given, and returns the result.
+ */
+export function callSync(plugin: string, method: string, ...args: any[]): any {
+  return valueFromUTF8Bytes(RPCSync(`plugin.${plugin}.${method}`, ...args));
+}
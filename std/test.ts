@@ -0,0 +1,176 @@
+/**
+ * @module std/test
+ *
+ * test has functions for writing tests of configuration code, to be
+ * run with `jk test`:
+ *
+ * ```
+ * import { test, equal, snapshot } from '@jkcfg/std/test';
+ * import { deployment } from './deployment';
+ *
+ * test('deployment has three replicas', () => {
+ *   equal(deployment({ replicas: 3 }).spec.replicas, 3);
+ * });
+ *
+ * test('deployment', () => {
+ *   snapshot(deployment({ name: 'frontend' }));
+ * });
+ * ```
+ */
+
+import { RPCSync } from './internal/rpc';
+import { valueFromUTF8Bytes } from './internal/data';
+
+export type TestFn = () => void | Promise<void>;
+
+interface TestCase {
+  name: string;
+  fn: TestFn;
+}
+
+const tests: TestCase[] = [];
+
+// The test currently running, and the number of snapshots taken in
+// it; used to name snapshots.
+let current = '';
+let snapshots = 0;
+
+/**
+ * test registers a test case, to be run by `jk test`. A test fails
+ * if the function throws an exception, or returns a promise that is
+ * rejected.
+ */
+export function test(name: string, fn: TestFn): void {
+  tests.push({ name, fn });
+}
+
+/**
+ * AssertionError is thrown by the assertion functions when an
+ * assertion doesn't hold.
+ */
+export class AssertionError extends Error {
+  actual: any;
+  expected: any;
+
+  constructor(message: string, actual?: any, expected?: any) {
+    super(message);
+    this.name = 'AssertionError';
+    this.actual = actual;
+    this.expected = expected;
+  }
+}
+
+function show(v: any): string {
+  if (v === undefined) return 'undefined';
+  return JSON.stringify(v, null, 2);
+}
+
+function deepEqual(a: any, b: any): boolean {
+  if (a === b) return true;
+  if (typeof a !== 'object' || typeof b !== 'object' || a === null || b === null) {
+    // NaN is the only value not equal to itself
+    return a !== a && b !== b; // eslint-disable-line no-self-compare
+  }
+  if (Array.isArray(a) !== Array.isArray(b)) return false;
+  const keysA = Object.keys(a);
+  const keysB = Object.keys(b);
+  if (keysA.length !== keysB.length) return false;
+  return keysA.every(k => Object.prototype.hasOwnProperty.call(b, k) && deepEqual(a[k], b[k]));
+}
+
+/**
+ * ok asserts that a value is truthy.
+ */
+export function ok(value: any, message?: string): void {
+  if (!value) {
+    throw new AssertionError(message || `expected a truthy value, got ${show(value)}`, value, true);
+  }
+}
+
+/**
+ * equal asserts that two values are equal; objects and arrays are
+ * compared by their contents.
+ */
+export function equal(actual: any, expected: any, message?: string): void {
+  if (!deepEqual(actual, expected)) {
+    throw new AssertionError(message || `expected ${show(expected)}, got ${show(actual)}`, actual, expected);
+  }
+}
+
+/**
+ * notEqual asserts that two values are not equal, comparing objects
+ * and arrays by their contents.
+ */
+export function notEqual(actual: any, expected: any, message?: string): void {
+  if (deepEqual(actual, expected)) {
+    throw new AssertionError(message || `expected a value other than ${show(expected)}`, actual, expected);
+  }
+}
+
+/**
+ * throws asserts that calling the function given throws an
+ * exception. If a string or regular expression is given, the
+ * exception's message must contain or match it.
+ */
+export function throws(fn: () => any, expected?: string | RegExp, message?: string): void {
+  try {
+    fn();
+  } catch (err) {
+    const msg = (err && err.message) || String(err);
+    if (expected === undefined
+        || (typeof expected === 'string' && msg.includes(expected))
+        || (expected instanceof RegExp && expected.test(msg))) {
+      return;
+    }
+    throw new AssertionError(message || `expected exception matching ${String(expected)}, got ${show(msg)}`, msg, expected);
+  }
+  throw new AssertionError(message || 'expected an exception to be thrown');
+}
+
+/**
+ * snapshot asserts that a value is the same as when the snapshot was
+ * last recorded. Snapshots are kept in `__snapshots__`, next to the
+ * test file; a snapshot that doesn't exist yet is recorded, and
+ * `jk test --update` records all snapshots afresh. Snapshots are named
+ * after the test and a counter, unless a name is given.
+ */
+export function snapshot(value: any, name?: string): void {
+  if (value === undefined) {
+    throw new AssertionError('cannot take a snapshot of undefined');
+  }
+  snapshots += 1;
+  const key = name || `${current} ${snapshots}`;
+  const result = valueFromUTF8Bytes(RPCSync('test.snapshot', key, value));
+  if (!result.pass) {
+    throw new AssertionError(`snapshot ${show(key)} does not match\nexpected: ${show(result.expected)}\nactual: ${show(value)}`, value, result.expected);
+  }
+}
+
+function report(name: string, err: any, start: number): void {
+  const result: any = { name, pass: err === undefined, duration: Date.now() - start };
+  if (err !== undefined) {
+    result.error = (err && err.stack) || String(err);
+  }
+  RPCSync('test.result', result);
+}
+
+/**
+ * run runs each test registered, in order, and reports the results
+ * to `jk test`.
+ *
+ * @hidden
+ */
+export async function run(): Promise<void> {
+  for (const { name, fn } of tests) {
+    current = name;
+    snapshots = 0;
+    const start = Date.now();
+    try {
+      await fn();
+      report(name, undefined, start);
+    } catch (err) {
+      report(name, err, start);
+    }
+  }
+  RPCSync('test.done');
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jkcfg/jk/pkg/std"
+	"github.com/jkcfg/jk/pkg/testrun"
+)
+
+var testCmd = &cobra.Command{
+	Use:     "test [path...]",
+	Example: testExamples,
+	Short:   "Run the tests in *.test.js files",
+	Run:     runTests,
+}
+
+const testExamples = `
+  running all the tests under the current directory
+    jk test
+
+  running the tests in a directory, and reporting in JUnit XML
+    jk test --reporter junit ./lib > results.xml
+
+  recording the snapshots used in tests afresh
+    jk test --update
+`
+
+var testOptions struct {
+	vmOptions
+	update   bool
+	reporter string
+}
+
+// testFileRun is the state of a test file being run, which the RPC
+// methods called by '@jkcfg/std/test' update. The methods are only
+// registered with the VM running the test file.
+type testFileRun struct {
+	suite     *testrun.Suite
+	snapshots *testrun.Snapshots
+	done      bool
+}
+
+func init() {
+	initExecFlags(testCmd, &testOptions.vmOptions)
+	testCmd.PersistentFlags().BoolVarP(&testOptions.update, "update", "u", false, "record snapshots afresh, rather than failing tests when they don't match")
+	testCmd.PersistentFlags().StringVar(&testOptions.reporter, "reporter", "tap", "how to report results: tap or junit")
+
+	jk.AddCommand(testCmd)
+}
+
+func (run *testFileRun) result(args []interface{}) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, errors.New("test.result: expected one argument")
+	}
+	data, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Name     string  `json:"name"`
+		Pass     bool    `json:"pass"`
+		Error    string  `json:"error"`
+		Duration float64 `json:"duration"` // milliseconds
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	run.suite.Results = append(run.suite.Results, testrun.Result{
+		Name:     result.Name,
+		Pass:     result.Pass,
+		Error:    result.Error,
+		Duration: time.Duration(result.Duration * float64(time.Millisecond)),
+	})
+	return true, nil
+}
+
+func (run *testFileRun) snapshot(args []interface{}) (interface{}, error) {
+	if len(args) != 2 {
+		return nil, errors.New("test.snapshot: expected two arguments")
+	}
+	name, ok := args[0].(string)
+	if !ok {
+		return nil, errors.New("test.snapshot: expected string as first argument")
+	}
+	pass, expected, err := run.snapshots.Check(name, args[1])
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"pass": pass, "expected": expected}, nil
+}
+
+func (run *testFileRun) finish(args []interface{}) (interface{}, error) {
+	run.done = true
+	return true, nil
+}
+
+func runTests(cmd *cobra.Command, args []string) {
+	switch testOptions.reporter {
+	case "tap", "junit":
+	default:
+		log.Fatalf("test: unknown reporter %q (expected tap or junit)", testOptions.reporter)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	applyProjectConfig(cmd, &testOptions.vmOptions, cwd)
+
+	paths := args
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := testrun.Discover(paths)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(files) == 0 {
+		log.Fatalf("test: no test files found (test files are named *%s)", testrun.TestFileSuffixes[0])
+	}
+
+	var suites []*testrun.Suite
+	failed := false
+	for _, file := range files {
+		suite := runTestFile(cwd, file)
+		failed = failed || suite.Failed()
+		suites = append(suites, suite)
+	}
+
+	switch testOptions.reporter {
+	case "tap":
+		err = testrun.WriteTAP(os.Stdout, suites)
+	case "junit":
+		err = testrun.WriteJUnit(os.Stdout, suites)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runTestFile runs the tests in a file, in a VM of its own.
+func runTestFile(cwd, file string) *testrun.Suite {
+	if filepath.IsAbs(file) {
+		if rel, err := filepath.Rel(cwd, file); err == nil {
+			file = rel
+		}
+	}
+	file = filepath.ToSlash(file)
+
+	suite := &testrun.Suite{File: file}
+	snapshots, err := testrun.LoadSnapshots(testrun.SnapshotPath(file), testOptions.update)
+	if err != nil {
+		suite.Err = err
+		return suite
+	}
+	run := &testFileRun{suite: suite, snapshots: snapshots}
+
+	opts := testOptions.vmOptions
+	vm := newVM(&opts, ".")
+	vm.runtime.RegisterMethod("test.result", run.result)
+	vm.runtime.RegisterMethod("test.snapshot", run.snapshot)
+	vm.runtime.RegisterMethod("test.done", run.finish)
+	module := fmt.Sprintf(string(std.Module("cmd/test-module.js")), file)
+	if err := vm.Run("@jkcfg/std/cmd/<test>", module); err != nil {
+		suite.Err = err
+	} else if !run.done {
+		suite.Err = errors.New("the tests did not all finish; is there a promise that is never resolved?")
+	}
+
+	if err := snapshots.Save(suite.Err == nil); err != nil && suite.Err == nil {
+		suite.Err = err
+	}
+	return suite
+}
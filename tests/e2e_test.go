@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"flag"
 	"io/ioutil"
 	"net/http/httptest"
 	"os"
@@ -12,9 +13,12 @@ import (
 	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/jkcfg/jk/pkg/std"
 	"github.com/jkcfg/jk/pkg/test"
 )
 
+var update = flag.Bool("update", false, "rewrite the expected output of tests with their actual output")
+
 func listTestFiles(t *testing.T) []string {
 	// Some tests aren't actually in this directory, but a .cmd file is used to
 	// tune how jk is run. We need to account for those, making sure tests with
@@ -85,9 +89,48 @@ func TestEndToEnd(t *testing.T) {
 			t.Fatal(err)
 		}
 		testEnv := append(env, "TEMP="+testTmp)
-		test := test.New(file, test.Options{Env: testEnv})
+		test := test.New(file, test.Options{Env: testEnv, Update: *update})
+		t.Run(test.Name(), func(t *testing.T) {
+			test.Run(t)
+		})
+	}
+}
+
+// TestInProcess runs some of the tests with a VM in the test process,
+// rather than a jk binary, to check they come out the same.
+func TestInProcess(t *testing.T) {
+	files := []string{
+		"test-jsonstream.js",
+		"test-parse.js",
+		"test-read.js",
+		"test-stdin.js",
+		"test-write-hcl.js",
+		"test-yamlstream.js",
+	}
+
+	for _, file := range files {
+		test := test.New(file, test.Options{
+			InProcess: true,
+			Parallel:  true,
+			Update:    *update,
+		})
 		t.Run(test.Name(), func(t *testing.T) {
 			test.Run(t)
 		})
 	}
+
+	// This is only run in process, since it's given parameters and an
+	// input directory.
+	test := test.New("inprocess/test-params-input.js", test.Options{
+		InProcess: true,
+		Parallel:  true,
+		Update:    *update,
+		Parameters: std.Params{
+			"app": map[string]interface{}{"name": "web", "replicas": 3.0},
+		},
+		InputDirectory: "inprocess/input",
+	})
+	t.Run(test.Name(), func(t *testing.T) {
+		test.Run(t)
+	})
 }
@@ -0,0 +1,11 @@
+import * as std from '@jkcfg/std';
+import * as param from '@jkcfg/std/param';
+
+const name = param.String('app.name', 'app');
+const replicas = param.Number('app.replicas', 1);
+
+std.read('app.yaml').then((base) => {
+  const app = { ...base, name, replicas };
+  std.print(app);
+  std.write(app, `${name}.json`);
+});
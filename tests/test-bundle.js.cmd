@@ -0,0 +1 @@
+jk bundle %b/main.js | jk run -
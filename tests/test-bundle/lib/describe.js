@@ -0,0 +1,3 @@
+export function describe(svc) {
+  return `${svc.name} listens on ${svc.port}`;
+}
@@ -0,0 +1,5 @@
+import * as std from '@jkcfg/std';
+import { describe } from './lib/describe';
+import service from './service.yaml';
+
+std.log(describe(service));
@@ -0,0 +1 @@
+jk deps %b/main.js | sed 's# /.*/jk/tests/# /jk/tests/#'
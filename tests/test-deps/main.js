@@ -0,0 +1,5 @@
+import * as std from '@jkcfg/std';
+import a from './lib/a';
+import b from './lib/b';
+
+std.log(a + b);
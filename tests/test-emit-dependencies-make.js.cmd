@@ -0,0 +1 @@
+jk run -o out -f test-run-dependencies/params.json --emit-dependencies=make %b.js | sed 's#/[^ ]*/jk/tests/#/jk/tests/#g'
@@ -0,0 +1,6 @@
+import * as std from '@jkcfg/std';
+import { foo } from './test-run-dependencies/failure';
+
+std.write({ foo }, 'config.yaml');
+std.write({ foo }, 'config.json');
+std.read('test-run-dependencies/svc-myapp.yaml');
@@ -0,0 +1,8 @@
+import * as std from '@jkcfg/std';
+
+std.print({ set: std.env('JK_TEST_GREETING'), unset: std.env('JK_TEST_UNSET') });
+try {
+  std.env('HOME');
+} catch (e) {
+  std.print(e.message);
+}
@@ -0,0 +1 @@
+JK_TEST_GREETING=hello jk run --env-allow 'JK_TEST_*' %f
@@ -0,0 +1,4 @@
+import * as std from '@jkcfg/std';
+
+std.exec('sh', { args: ['-c', 'echo ran'] })
+  .then(({ stdout }) => std.print(stdout), e => std.print(e.message));
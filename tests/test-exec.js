@@ -0,0 +1,8 @@
+import * as std from '@jkcfg/std';
+
+std.exec('sh', { args: ['-c', 'read name; echo "hello $name"'], stdin: 'world\n' })
+  .then(({ stdout, code }) => std.print({ stdout, code }))
+  .then(() => std.exec('sh', { args: ['-c', 'echo "$COLOUR" >&2; exit 2'], env: { COLOUR: 'blue' } }))
+  .then(({ stderr, code }) => std.print({ stderr, code }))
+  .then(() => std.exec('rm', { args: ['-rf', '/'] }))
+  .catch(e => std.print(e.message));
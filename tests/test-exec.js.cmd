@@ -0,0 +1 @@
+jk run --allow-exec sh %f
@@ -0,0 +1,4 @@
+rm -rf ${TMPDIR:-/tmp}/jk-test-generate-incremental-plugin
+jk generate --incremental --cache ${TMPDIR:-/tmp}/jk-test-generate-incremental-plugin --plugin inventory=test-plugin/inventory.sh -o %b.got %b/index.js
+rm -r %b.got
+jk generate -v --incremental --cache ${TMPDIR:-/tmp}/jk-test-generate-incremental-plugin --plugin inventory=test-plugin/inventory.sh -o %b.got %b/index.js
@@ -0,0 +1,5 @@
+import { callSync } from '@jkcfg/std/plugin';
+
+export default [
+  { path: 'owner.yaml', value: callSync('inventory', 'owner', 'web-1') },
+];
@@ -0,0 +1,4 @@
+rm -rf ${TMPDIR:-/tmp}/jk-test-generate-incremental
+jk generate --incremental --cache ${TMPDIR:-/tmp}/jk-test-generate-incremental -o %b.got %b/index.js
+rm -r %b.got
+jk generate -v --incremental --cache ${TMPDIR:-/tmp}/jk-test-generate-incremental -o %b.got %b/index.js
@@ -0,0 +1,5 @@
+import * as std from '@jkcfg/std';
+
+export default std.read('replicas.json').then(replicas => [
+  { path: 'config.yaml', value: { replicas } },
+]);
@@ -0,0 +1 @@
+jk run -d %b/index.js | sed 's#^\(.*"path": "\).*\(/jk/tests/.*\)$#\1\2#'
@@ -0,0 +1,3 @@
+import values from './values.yaml';
+
+export default values;
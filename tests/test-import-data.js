@@ -0,0 +1,6 @@
+import * as std from '@jkcfg/std';
+import json from './foo.json';
+import yaml from './foo.yaml';
+
+std.log(json.foo);
+std.log(yaml.config.foo);
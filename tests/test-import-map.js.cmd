@@ -0,0 +1 @@
+jk run --import-map %b/import-map.json %b/index.js
@@ -0,0 +1,6 @@
+import * as std from '@jkcfg/std';
+import greeting from '@corp/greeting';
+import prefixed from '@corp/greeting/prefix';
+
+std.log(greeting);
+std.log(prefixed);
@@ -0,0 +1 @@
+cd %b && jk test
@@ -0,0 +1,11 @@
+export function deployment({ name, replicas = 1 }) {
+  if (!name) {
+    throw new Error('a deployment needs a name');
+  }
+  return {
+    apiVersion: 'apps/v1',
+    kind: 'Deployment',
+    metadata: { name },
+    spec: { replicas },
+  };
+}
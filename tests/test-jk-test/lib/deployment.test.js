@@ -0,0 +1,21 @@
+import {
+  test, ok, equal, throws, snapshot,
+} from '@jkcfg/std/test';
+import { deployment } from './deployment';
+
+test('has the replicas given', () => {
+  equal(deployment({ name: 'app', replicas: 3 }).spec.replicas, 3);
+});
+
+test('needs a name', () => {
+  throws(() => deployment({}), 'needs a name');
+});
+
+test('can be asynchronous', async () => {
+  const d = await Promise.resolve(deployment({ name: 'app' }));
+  ok(d.metadata.name === 'app');
+});
+
+test('frontend', () => {
+  snapshot(deployment({ name: 'frontend' }));
+});
@@ -0,0 +1 @@
+jk run --cache="${TEMP}" -c "import foo from 'oci://${REGISTRY}/foolib:v1/foolib'; log(foo);"
@@ -0,0 +1,11 @@
+/* eslint "import/no-unresolved": [2, { ignore: ['.*'] }] */
+import * as std from '@jkcfg/std';
+
+// node_modules/exportcase/package.json maps '.' to src/index.js under
+// the condition `jk`, which imports '#message' via `imports`
+import msg from 'exportcase';
+// ... and './lib/*' to src/lib/*.js
+import pattern from 'exportcase/lib/pattern';
+
+std.log(msg);
+std.log(pattern);
@@ -0,0 +1,11 @@
+import * as std from '@jkcfg/std';
+import * as param from '@jkcfg/std/param';
+
+std.print({
+  ports: param.Array('ports', [8080]),
+  servers: param.Array('servers', []),
+  replicas: param.Number('replicas', 1),
+  enabled: param.Boolean('enabled', false),
+  labels: param.Object('labels', {}),
+  missing: param.Array('missing', ['default']),
+});
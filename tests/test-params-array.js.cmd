@@ -0,0 +1 @@
+jk run -p 'ports:=[80, 443]' -p 'servers[1].name=b' -p 'servers[0].name=a' -p 'servers[1].weight:=2' -p replicas:=3 -p enabled:=true -p 'labels:={"app": "web"}' %f
@@ -0,0 +1 @@
+jk run -p replicas:=20 test-params-check-first/index.js 2>&1 | cat
@@ -0,0 +1,6 @@
+import * as param from '@jkcfg/std/param';
+import './prints.js';
+
+param.declare({
+  replicas: { type: 'number', schema: { maximum: 10 } },
+});
@@ -0,0 +1,5 @@
+import * as std from '@jkcfg/std';
+
+// This runs before the parameters are declared; with bad parameters,
+// it shouldn't run at all.
+std.print('imported');
@@ -0,0 +1 @@
+jk run -p name=web -p replicas:=20 test-params-declare.js
@@ -0,0 +1 @@
+jk run -p replicas=2 test-params-declare.js
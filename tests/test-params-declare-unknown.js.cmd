@@ -0,0 +1 @@
+jk run --strict-params -p name=web -p replcas=2 test-params-declare.js
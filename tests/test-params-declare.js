@@ -0,0 +1,19 @@
+import * as std from '@jkcfg/std';
+import * as param from '@jkcfg/std/param';
+
+param.declare({
+  name: { type: 'string', description: 'the name of the app', required: true },
+  replicas: {
+    type: 'number',
+    description: 'how many to run',
+    default: 1,
+    schema: { minimum: 1, maximum: 10 },
+  },
+  ports: { type: 'array', description: 'the ports to expose', default: [80] },
+});
+
+std.print({
+  name: param.String('name'),
+  replicas: param.Number('replicas'),
+  ports: param.Array('ports'),
+});
@@ -0,0 +1 @@
+jk run --strict-params -p name=web -p replicas=3 %f
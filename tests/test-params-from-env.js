@@ -0,0 +1,8 @@
+import * as std from '@jkcfg/std';
+import * as param from '@jkcfg/std/param';
+
+std.print({
+  port: param.Number('db.port', 3306),
+  host: param.String('db.host_name', 'localhost'),
+  debug: param.Boolean('debug', false),
+});
@@ -0,0 +1 @@
+APP_DB__PORT=5432 APP_DB__HOST_NAME=db.example.com APP_DEBUG=true jk run -p db.host_name=override --params-from-env APP %f
@@ -0,0 +1,7 @@
+import * as param from '@jkcfg/std/param';
+
+param.declare({
+  name: { type: 'string', description: 'the name of the app', required: true },
+});
+
+throw new Error(`cannot deploy ${param.String('name')} from here`);
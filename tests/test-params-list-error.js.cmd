@@ -0,0 +1 @@
+jk params test-params-list-error.js 2>/dev/null
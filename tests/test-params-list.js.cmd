@@ -0,0 +1 @@
+jk params test-params-declare.js
@@ -0,0 +1,15 @@
+import * as std from '@jkcfg/std';
+import { call, callSync } from '@jkcfg/std/plugin';
+
+std.print(callSync('inventory', 'owner', 'web-1'));
+
+try {
+  callSync('inventory', 'owner', 'db-1');
+} catch (e) {
+  std.print(e.message);
+}
+
+call('inventory', 'owner', 'web-1')
+  .then(owner => std.print(owner))
+  .then(() => call('inventory', 'crash'))
+  .catch(e => std.print(e.message));
@@ -0,0 +1 @@
+jk run --plugin inventory=test-plugin/inventory.sh %f
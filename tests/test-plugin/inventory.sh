@@ -0,0 +1,17 @@
+#!/bin/sh
+# A plugin for testing: `owner` answers with the owner of a host, and
+# `crash` exits, having complained on stderr.
+while read -r line; do
+  id=$(echo "$line" | sed 's/.*"id":\([0-9]*\).*/\1/')
+  case "$line" in
+    *'"method":"describe"'*)
+      echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":{\"methods\":[\"owner\",\"crash\"]}}" ;;
+    *'"method":"owner"'*'"web-1"'*)
+      echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":{\"team\":\"frontend\"}}" ;;
+    *'"method":"owner"'*)
+      echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"error\":{\"code\":1,\"message\":\"unknown host\"}}" ;;
+    *'"method":"crash"'*)
+      echo "inventory: out of cheese" >&2
+      exit 1 ;;
+  esac
+done
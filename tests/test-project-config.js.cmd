@@ -0,0 +1 @@
+jk run -p override=from-command-line %b/index.js
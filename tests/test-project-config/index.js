@@ -0,0 +1,5 @@
+import * as std from '@jkcfg/std';
+import * as param from '@jkcfg/std/param';
+
+std.log(param.String('name'));
+std.log(param.String('override'));
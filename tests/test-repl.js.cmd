@@ -0,0 +1 @@
+jk repl
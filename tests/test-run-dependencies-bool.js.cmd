@@ -0,0 +1 @@
+jk run -f test-run-dependencies/params.json -d=false --emit-dependencies=true test-run-dependencies.js | sed 's#^\(.*"path": "\).*\(/jk/tests/.*\)$#\1\2#'
@@ -0,0 +1,10 @@
+import * as fs from '@jkcfg/std/fs';
+import * as param from '@jkcfg/std/param';
+import { validateWithFile } from '@jkcfg/std/schema';
+
+const replicas = param.Number('replicas', 1);
+param.String('name', 'myapp');
+
+fs.info('testfs/foo.txt');
+fs.dir('testfs/bar');
+validateWithFile({ replicas }, 'validate-schema-files/person.json');
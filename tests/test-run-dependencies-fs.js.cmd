@@ -0,0 +1 @@
+jk run -p replicas=3 -d %b.js | sed 's#^\(.*"path": "\).*\(/jk/tests/.*\)$#\1\2#'
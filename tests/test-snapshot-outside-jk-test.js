@@ -0,0 +1,8 @@
+import * as std from '@jkcfg/std';
+import { snapshot } from '@jkcfg/std/test';
+
+try {
+  snapshot(1, 'one');
+} catch (e) {
+  std.print(e.message);
+}
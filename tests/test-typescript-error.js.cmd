@@ -0,0 +1 @@
+jk run %b/main.ts 2>&1 | grep -o -E 'check\.ts:[0-9]+|^> +[0-9]+ \|'
@@ -0,0 +1,9 @@
+// Types are stripped when loading, so line and column numbers in the
+// generated JavaScript differ from those here.
+type Count = number;
+
+export function check(n: Count): void {
+  if (n < 0) {
+    throw new Error(`expected a non-negative number, got ${n}`);
+  }
+}
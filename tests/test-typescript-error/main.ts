@@ -0,0 +1,8 @@
+import { check } from './check';
+
+interface Config {
+  replicas: number;
+}
+
+const config: Config = { replicas: -1 };
+check(config.replicas);
@@ -0,0 +1 @@
+jk run %b/main.ts
@@ -0,0 +1,5 @@
+import * as std from '@jkcfg/std';
+import { Service, serviceName } from './service';
+
+const svc: Service = { name: 'frontend', port: 8080 };
+std.log(serviceName(svc));
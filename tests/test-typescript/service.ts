@@ -0,0 +1,8 @@
+export interface Service {
+  name: string;
+  port: number;
+}
+
+export function serviceName(s: Service): string {
+  return `${s.name}:${s.port}`;
+}
@@ -47,21 +47,18 @@ func transformArgs(cmd *cobra.Command, args []string) error {
 }
 
 func transform(cmd *cobra.Command, args []string) {
+	applyProjectConfig(cmd, &transformOptions.vmOptions, establishScriptDir(transformOptions.scriptOptions, args[0]))
+
 	// We must use the current directory as the working directory (for
 	// the purpose of resolving modules), because we're potentially
 	// going to supply a path _relative to here_ as an import.
 	vm := newVM(&transformOptions.vmOptions, ".")
 
-	// Encode the inputs as a map of path to .. the same path (for
-	// now). This is in part to get around the limitations of
-	// parameters (arrays are not supported as values), and partly in
-	// anticipation of there being more information to pass about each
-	// input.
-	inputs := make(map[string]interface{})
-	for _, f := range args[1:] {
-		inputs[f] = f
+	inputs := make([]interface{}, len(args)-1)
+	for i, f := range args[1:] {
+		inputs[i] = f
 	}
-	vm.parameters.Set("jk.transform.input", inputs)
+	vm.parameters.SetArray("jk.transform.input", inputs)
 	vm.parameters.Set("jk.transform.stdout", transformOptions.stdout)
 	vm.parameters.Set("jk.transform.overwrite", transformOptions.overwrite)
 
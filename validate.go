@@ -47,13 +47,14 @@ func validateArgs(cmd *cobra.Command, args []string) error {
 }
 
 func validate(cmd *cobra.Command, args []string) {
+	applyProjectConfig(cmd, &validateOptions.vmOptions, establishScriptDir(validateOptions.scriptOptions, args[0]))
 	vm := newVM(&validateOptions.vmOptions, ".")
 
-	inputs := make(map[string]interface{})
-	for _, f := range args[1:] {
-		inputs[f] = f
+	inputs := make([]interface{}, len(args)-1)
+	for i, f := range args[1:] {
+		inputs[i] = f
 	}
-	vm.parameters.Set("jk.validate.input", inputs)
+	vm.parameters.SetArray("jk.validate.input", inputs)
 
 	var module string
 	switch {
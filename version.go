@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -22,3 +23,21 @@ var Version = "git"
 func version(cmd *cobra.Command, args []string) {
 	fmt.Println("version:", Version)
 }
+
+// buildID identifies the build of jk, for the purpose of caching
+// results. Builds that aren't released all have the same Version, so
+// for those the executable's size and modification time are included.
+func buildID() string {
+	if Version != "git" {
+		return Version
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return Version
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return Version
+	}
+	return fmt.Sprintf("%s-%d-%d", Version, info.Size(), info.ModTime().UnixNano())
+}
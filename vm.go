@@ -3,25 +3,23 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/jkcfg/jk/pkg/cli"
-	"github.com/jkcfg/jk/pkg/deferred"
-	"github.com/jkcfg/jk/pkg/image"
-	"github.com/jkcfg/jk/pkg/image/cache"
+	jkruntime "github.com/jkcfg/jk/pkg/jk"
 	"github.com/jkcfg/jk/pkg/record"
 	"github.com/jkcfg/jk/pkg/resolve"
 	"github.com/jkcfg/jk/pkg/std"
-	"github.com/jkcfg/jk/pkg/vfs"
-	v8 "github.com/jkcfg/v8worker2"
 )
 
 // vmOptions are the options common (mostly) to all subcommands. Not
@@ -36,32 +34,89 @@ type vmOptions struct {
 	libraryImages    []name.Reference
 	parameters       std.Params
 	parameterFiles   []string // list of files specified on the command line with -f.
-	emitDependencies bool
+	paramsFromEnv    string   // the prefix of environment variables to take parameters from
+	strictParams     bool     // fail if parameters are supplied that scripts don't declare
+	listParams       bool     // collect the parameters declared, without writing anything (for `jk params`)
+	configFile       string   // the project configuration file, if one was found
+	importMap        string
+	emitDependencies string // the format for emitting dependencies, if they are to be emitted
+	incremental      bool   // record what's used and written, for the run cache
+	recordFile       string // a file to write the recording to, for `jk watch`
+	plugins          []pluginSpec
+	allowExec        []string // commands scripts may run with std.exec
+	envAllow         []string // patterns for the environment variables scripts may look up
+	pluginTimeout    time.Duration
 
 	debugImports bool
 }
 
+// dependenciesFormat is the value of --emit-dependencies. That was a
+// boolean flag before there were formats other than JSON, so true and
+// false are accepted, meaning json and not emitting dependencies.
+type dependenciesFormat struct {
+	format *string
+}
+
+func (d dependenciesFormat) String() string {
+	return *d.format
+}
+
+func (d dependenciesFormat) Set(s string) error {
+	switch s {
+	case "true":
+		s = "json"
+	case "false":
+		s = ""
+	case "json", "make", "ninja":
+	default:
+		return fmt.Errorf("unknown dependencies format %q (expected json, make or ninja)", s)
+	}
+	*d.format = s
+	return nil
+}
+
+func (d dependenciesFormat) Type() string {
+	return "format"
+}
+
 // initInputFlags adds flags controlling input, to the given command
 func initInputFlags(cmd *cobra.Command, opts *vmOptions) {
 	cmd.PersistentFlags().StringVarP(&opts.inputDirectory, "input-directory", "i", "", "where to find files read in the script; if not set, the directory containing the script is used")
 }
 
+// initModuleFlags adds flags controlling how modules are found, to
+// the given command
+func initModuleFlags(cmd *cobra.Command, opts *vmOptions) {
+	cmd.PersistentFlags().Var(cli.NewImageRefSliceValue(&opts.libraryImages), "lib", "use image in module search path, downloading it if necessary")
+	cmd.PersistentFlags().StringVar(&opts.cacheDir, "cache", "", "directory to use for caching downloaded images; if empty, the default for the OS will be used")
+	cmd.PersistentFlags().StringVar(&opts.importMap, "import-map", "", "rewrite import specifiers using the import map in the given JSON file")
+	cmd.MarkPersistentFlagFilename("import-map", "json")
+	cmd.PersistentFlags().BoolVar(&opts.debugImports, "debug-imports", false, "trace import logic")
+	cmd.PersistentFlags().MarkHidden("debug-imports")
+}
+
 // initExecFlags adds flags controlling execution, to the given command
 func initExecFlags(cmd *cobra.Command, opts *vmOptions) {
 	opts.parameters = std.NewParams()
 
-	cmd.PersistentFlags().Var(cli.NewImageRefSliceValue(&opts.libraryImages), "lib", "use image in module search path, downloading it if necessary")
-	cmd.PersistentFlags().StringVar(&opts.cacheDir, "cache", "", "directory to use for caching downloaded images; if empty, the default for the OS will be used")
+	initModuleFlags(cmd, opts)
 	cmd.PersistentFlags().BoolVarP(&opts.verbose, "verbose", "v", false, "verbose output")
 	cmd.PersistentFlags().StringVarP(&opts.outputDirectory, "output-directory", "o", "", "where to output generated files")
-	cmd.PersistentFlags().VarP(parameters(opts, paramSourceCommandLine), "parameter", "p", "set input parameters")
+	cmd.PersistentFlags().VarP(parameters(opts, paramSourceCommandLine), "parameter", "p", "set input parameters, as name=value, or name:=<json> for values other than strings; name may include array indices, e.g., a.b[2].c")
 	parameterFlag := cmd.PersistentFlags().VarPF(parameters(opts, paramSourceFile), "parameters", "f", "load parameters from a JSON or YAML file")
 	parameterFlag.Annotations = map[string][]string{
 		cobra.BashCompFilenameExt: {"json", "yaml", "yml"},
 	}
-	cmd.PersistentFlags().BoolVarP(&opts.emitDependencies, "emit-dependencies", "d", false, "emit script dependencies")
-	cmd.PersistentFlags().BoolVar(&opts.debugImports, "debug-imports", false, "trace import logic")
-	cmd.PersistentFlags().MarkHidden("debug-imports")
+	cmd.PersistentFlags().StringVar(&opts.paramsFromEnv, "params-from-env", "", "set input parameters from the environment variables with the prefix given, e.g., PREFIX_A__B=value sets a.b (overridden by -f and -p)")
+	cmd.PersistentFlags().BoolVar(&opts.strictParams, "strict-params", false, "fail if parameters are supplied that scripts don't declare with param.declare")
+	cmd.PersistentFlags().VarP(dependenciesFormat{&opts.emitDependencies}, "emit-dependencies", "d", "emit script dependencies instead of writing files, as json, make (a Makefile rule) or ninja (a build statement for the rule jk)")
+	cmd.PersistentFlags().Lookup("emit-dependencies").NoOptDefVal = "json"
+	cmd.PersistentFlags().StringArrayVar(&opts.allowExec, "allow-exec", nil, "allow scripts to run the command given with std.exec (may be repeated)")
+	cmd.PersistentFlags().StringArrayVar(&opts.envAllow, "env-allow", nil, "allow scripts to look up the environment variables matching the pattern given (e.g., 'CI_*') with std.env (may be repeated)")
+	cmd.PersistentFlags().Var(plugins(opts), "plugin", "run the program at path as a plugin, making its methods available to scripts as plugin.<name>.<method>")
+	cmd.PersistentFlags().DurationVar(&opts.pluginTimeout, "plugin-timeout", 30*time.Second, "how long to wait for a plugin to answer a call")
+	cmd.PersistentFlags().StringVar(&opts.recordFile, "record-dependencies", "", "write the dependencies of the run to the file given, as JSON, while running as usual")
+	cmd.PersistentFlags().MarkHidden("record-dependencies")
 }
 
 func initAllVMFlags(cmd *cobra.Command, opts *vmOptions) {
@@ -69,17 +124,6 @@ func initAllVMFlags(cmd *cobra.Command, opts *vmOptions) {
 	initExecFlags(cmd, opts)
 }
 
-const errorHandler = `
-function onerror(msg, src, line, col, err) {
-  V8Worker2.log("Promise rejected at", src, line + ":" + col);
-  V8Worker2.log(err.stack);
-}
-`
-
-const global = `
-var global = {};
-`
-
 func echo(args []interface{}) (interface{}, error) {
 	// json.Marshal will serialise a []byte as base64-encoded;
 	// stop it doing that by making all such args into []int
@@ -96,6 +140,8 @@ func echo(args []interface{}) (interface{}, error) {
 	return args, nil
 }
 
+// rpcExtMethods are the extension RPC methods registered with each
+// VM.
 var rpcExtMethods = map[string]std.RPCFunc{
 	"debug.echo": echo,
 }
@@ -103,209 +149,194 @@ var rpcExtMethods = map[string]std.RPCFunc{
 type vm struct {
 	vmOptions
 
-	scriptDir         string
-	inputDir          string
-	moduleFilesystems []vfs.FileSystem
-
-	worker    *v8.Worker
+	runtime   *jkruntime.Runtime
+	scriptDir string
 	recorder  *record.Recorder
-	std       *std.Std
-	resources *std.ModuleResources
-}
-
-func (vm *vm) onMessageReceived(msg []byte) []byte {
-	return vm.std.Execute(msg, vm.worker)
 }
 
 func newVM(opts *vmOptions, workingDirectory string) *vm {
 	vm := &vm{
 		vmOptions: *opts,
-		resources: std.NewModuleResources(),
-	}
-
-	/*
-	 * Set scriptDir/inputDir based on workingDirectory and global options.
-	 * This needs to be done early on as these values are used by both the
-	 * stdlib and the module resolving mechanism.
-	 */
-	vm.setWorkingDirectory(workingDirectory)
-
-	if vm.vmOptions.cacheDir == "" {
-		userCache, err := os.UserCacheDir()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "cannot determine user cache dir; using ./.jk for cache")
-			vm.vmOptions.cacheDir = "./.jk"
-		} else {
-			vm.vmOptions.cacheDir = filepath.Join(userCache, "jk")
-		}
-	}
-
-	cache := cache.New(vm.vmOptions.cacheDir)
-
-	for _, lib := range opts.libraryImages {
-		imgVfs, err := cache.EnsureImage(lib.String())
-		if err != nil {
-			log.Fatalf("run: unable to fetch image %q: %s", lib, err.Error())
-		}
-		imgVfs = vfs.Chroot(imgVfs, image.ModulesDir)
-		vm.moduleFilesystems = append(vm.moduleFilesystems, imgVfs)
 	}
 
 	/* Setup a recorder object to gather the list of dependencies */
-	if opts.emitDependencies {
+	if opts.emitDependencies != "" || opts.incremental || opts.recordFile != "" {
 		recorder := &record.Recorder{}
 		// Add the parameter files to the list of dependencies.
 		cwd, err := os.Getwd()
 		if err != nil {
 			log.Fatal("run: unable to get current working directory:", err)
 		}
+		if opts.configFile != "" {
+			recorder.Record(record.ConfigFile, record.Params{
+				"path": opts.configFile,
+			})
+		}
+		if opts.importMap != "" {
+			path, _ := filepath.Abs(opts.importMap)
+			recorder.Record(record.ImportMapFile, record.Params{
+				"path": path,
+			})
+		}
 		for _, f := range opts.parameterFiles {
+			if !filepath.IsAbs(f) {
+				f = filepath.Join(cwd, f)
+			}
 			recorder.Record(record.ParameterFile, record.Params{
-				"path": filepath.Join(cwd, f),
+				"path": f,
 			})
 		}
 		vm.recorder = recorder
 	}
 
-	/* create the stdlib */
-	vm.std = std.NewStd(std.Options{
-		Verbose:    vm.verbose,
-		Parameters: vm.parameters,
-		Sandbox: std.Sandbox{
-			Base:      resolve.ScriptBase(vm.inputDir),
-			WriteRoot: opts.outputDirectory,
-			Modules:   vm.resources,
-			Recorder:  vm.recorder,
-		},
-		DryRun:     vm.emitDependencies,
-		ExtMethods: rpcExtMethods,
-	})
+	var libraries []string
+	for _, lib := range opts.libraryImages {
+		libraries = append(libraries, lib.String())
+	}
 
-	worker := v8.New(vm.onMessageReceived)
-	if err := worker.Load("errorHandler", errorHandler); err != nil {
-		log.Fatal(err)
+	// When only collecting the parameters declared, stdin is left
+	// for a run that follows (see checkDeclaredParams).
+	var stdin io.Reader
+	if opts.listParams {
+		stdin = strings.NewReader("")
 	}
-	if err := worker.Load("global", global); err != nil {
+
+	runtime, err := jkruntime.New(jkruntime.Options{
+		ScriptDirectory: workingDirectory,
+		InputDirectory:  opts.inputDirectory,
+		OutputDirectory: opts.outputDirectory,
+		CacheDirectory:  opts.cacheDir,
+		Libraries:       libraries,
+		ImportMap:       opts.importMap,
+		Parameters:      opts.parameters,
+		Verbose:         opts.verbose,
+		DryRun:          opts.emitDependencies != "" || opts.listParams,
+		Commands:        opts.allowExec,
+		EnvAllow:        opts.envAllow,
+		Recorder:        vm.recorder,
+		InMemory:        opts.listParams,
+		Stdin:           stdin,
+
+		StrictParams:        opts.strictParams,
+		SkipParamValidation: opts.listParams,
+	})
+	if err != nil {
+		log.Fatalf("run: %s", err.Error())
+	}
+	for name, fn := range rpcExtMethods {
+		runtime.RegisterMethod(name, fn)
+	}
+	methods, err := pluginMethods(opts.plugins, opts.pluginTimeout, vm.recorder)
+	if err != nil {
 		log.Fatal(err)
 	}
-	vm.worker = worker
+	for name, fn := range methods {
+		runtime.RegisterMethod(name, fn)
+	}
+	vm.runtime = runtime
+	vm.scriptDir = runtime.ScriptDirectory()
+	vm.cacheDir = runtime.CacheDirectory()
 
 	resolve.Debug(opts.debugImports)
 
 	return vm
 }
 
-func (vm *vm) setWorkingDirectory(dir string) {
-	scriptDir, err := filepath.Abs(dir)
-	if err != nil {
-		log.Fatal(err)
-	}
-	vm.scriptDir = scriptDir
-
-	inputDir := dir
-	if vm.inputDirectory != "" {
-		inputDir = vm.inputDirectory
+// checkDeclaredParams checks the parameters supplied against those
+// the script declares, before the script is run for real; so, bad
+// parameters are reported before the script, or any module it
+// imports, has done anything with them. To find the declarations,
+// the script is run with runScript in a dry run, as for `jk params`.
+//
+// This is skipped if no parameters are supplied, since then the
+// script can't be using bad values; and if commands are allowed,
+// since std.exec is rejected in a dry run, and a promise rejected
+// and not handled ends the process. In either case, the parameters
+// are still checked when param.declare is called.
+func checkDeclaredParams(opts vmOptions, scriptDir string, runScript func(*vm) error) {
+	if opts.emitDependencies != "" || len(opts.allowExec) > 0 || !paramsSupplied(opts.parameters) {
+		return
 	}
-
-	inputDir, err = filepath.Abs(inputDir)
-	if err != nil {
+	opts.listParams = true
+	opts.verbose = false
+	opts.incremental = false
+	opts.recordFile = ""
+	vm := newVM(&opts, scriptDir)
+	err := runScript(vm)
+	// The script may stop in the dry run where it wouldn't otherwise
+	// (e.g., reading a file it didn't write); what it declared before
+	// then is checked, and anything else is left to param.declare,
+	// including whether there are parameters not declared.
+	strict := opts.strictParams && err == nil
+	if err := std.CheckDeclaredParams(vm.runtime.ParamDeclarations(), opts.parameters, strict); err != nil {
 		log.Fatal(err)
 	}
-	vm.inputDir = inputDir
 }
 
-func (vm *vm) resolver() *resolve.Resolver {
-	hostFs := vfs.User("file://", http.Dir("/"))
-	workingDir := vfs.Location{Vfs: hostFs, Path: vm.inputDir}
-	hostModule, hostModulePath := vm.resources.MakeResourceModule(std.ModuleAccess{
-		Loc:                      workingDir,
-		AllowPathsOutsideSandbox: true,
-		AllowWriteToHost:         true,
-	})
-	makeHostModule := func(_ vfs.Location) ([]byte, string) {
-		return hostModule, hostModulePath
-	}
-
-	makeResourceModule := func(loc vfs.Location) ([]byte, string) {
-		return vm.resources.MakeResourceModule(std.ModuleAccess{
-			Loc: loc,
-		})
-	}
-
-	importers := []resolve.Importer{
-		&resolve.Relative{},
-		&resolve.MagicImporter{
-			Specifier: "@jkcfg/std/resource",
-			Generate:  makeResourceModule,
-			Public:    true,
-		},
-		&resolve.MagicImporter{Specifier: "@jkcfg/std/internal/host", Generate: makeHostModule},
-		&resolve.StdImporter{
-			// List here the modules users are allowed to access.
-			PublicModules: []string{"index.js", "param.js", "fs.js", "merge.js", "debug.js", "schema.js"},
-		},
-		resolve.NewFileImporter(vfs.User(vm.scriptDir, http.Dir(vm.scriptDir))),
-		resolve.NewNodeImporter(vfs.User(vm.scriptDir, http.Dir(vm.scriptDir))),
-	}
-
-	for _, fs := range vm.moduleFilesystems {
-		importers = append(importers, resolve.NewFileImporter(fs))
+// paramsSupplied says whether any parameters are given, other than
+// those jk sets for its own commands.
+func paramsSupplied(params std.Params) bool {
+	for k := range params {
+		if k != "jk" {
+			return true
+		}
 	}
+	return false
+}
 
-	resolver := resolve.NewResolver(vm.worker, resolve.ScriptBase(vm.scriptDir), importers...)
-	resolver.SetRecorder(vm.recorder)
-	return resolver
+// newResolver creates a resolver using the given loader, with the
+// importers set up according to the VM's options.
+func (vm *vm) newResolver(loader resolve.Loader) *resolve.Resolver {
+	return vm.runtime.NewResolver(loader)
 }
 
 func (vm *vm) Run(specifier string, source string) error {
-	resolver := vm.resolver()
-	if err := vm.worker.LoadModule(specifier, source, resolver.ResolveModule); err != nil {
+	if err := vm.runtime.Run(specifier, source); err != nil {
 		return err
 	}
 	return vm.flush()
 }
 
 func (vm *vm) RunModule(specifier string, referrer string) error {
-	resolver := vm.resolver()
-	_, ret := resolver.ResolveModule(specifier, referrer)
-	if ret != 0 {
-		err := fmt.Errorf("unable to load module %q", specifier)
-		return errors.Wrap(err, "run-module")
+	if err := vm.runtime.RunModule(specifier, referrer); err != nil {
+		return err
 	}
 	return vm.flush()
 }
 
 func (vm *vm) RunFile(filename string) error {
-	// Add the script to the list of dependencies.
-	if vm.recorder != nil {
-		abspath, _ := filepath.Abs(filename)
-		vm.recorder.Record(record.ImportFile, record.Params{
-			"specifier": filename,
-			"path":      abspath,
-		})
-	}
-	input, err := ioutil.ReadFile(filename)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	resolver := vm.resolver()
-	if err := vm.worker.LoadModule(filepath.Base(filename), string(input), resolver.ResolveModule); err != nil {
+	if err := vm.runtime.RunFile(filename); err != nil {
 		return err
 	}
-
 	return vm.flush()
 }
 
 func (vm *vm) flush() error {
-	deferred.Wait() // TODO(michael): hide this in std?
-
-	if vm.recorder != nil {
-		data, err := json.MarshalIndent(vm.recorder, "", "  ")
+	if vm.emitDependencies != "" {
+		var err error
+		switch vm.emitDependencies {
+		case "make":
+			err = vm.recorder.WriteMake(os.Stdout)
+		case "ninja":
+			err = vm.recorder.WriteNinja(os.Stdout, "jk")
+		default:
+			var data []byte
+			if data, err = json.MarshalIndent(vm.recorder, "", "  "); err == nil {
+				fmt.Println(string(data))
+			}
+		}
 		if err != nil {
 			return errors.Wrap(err, "emit-dependencies")
 		}
-		fmt.Println(string(data))
+	}
+	if vm.recordFile != "" {
+		data, err := json.Marshal(vm.recorder)
+		if err != nil {
+			return errors.Wrap(err, "record-dependencies")
+		}
+		if err := ioutil.WriteFile(vm.recordFile, data, 0644); err != nil {
+			return errors.Wrap(err, "record-dependencies")
+		}
 	}
 
 	return nil
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+
+	"github.com/jkcfg/jk/pkg/record"
+)
+
+var watchCmd = &cobra.Command{
+	Use:     "watch <command> [args...]",
+	Example: watchExamples,
+	Short:   "Run a command again whenever the files it uses change",
+	Args:    watchArgs,
+	Run:     watch,
+}
+
+const watchExamples = `
+  regenerating configuration whenever the script, or anything it imports or reads, changes
+    jk watch generate -o ./outputdir ./deployment.js
+
+  re-running a script, waiting until files have been quiet for half a second
+    jk watch --debounce 500ms run ./script.js
+`
+
+// watchCommands are the commands that can be watched; each of these
+// accepts --record-dependencies.
+var watchCommands = map[string]bool{
+	"generate": true,
+	"run":      true,
+	"validate": true,
+}
+
+var watchOptions struct {
+	debounce time.Duration
+	clear    bool
+}
+
+func init() {
+	// Flags after the command belong to the command
+	watchCmd.Flags().SetInterspersed(false)
+	watchCmd.Flags().DurationVar(&watchOptions.debounce, "debounce", 100*time.Millisecond, "how long to wait for files to stop changing before running again")
+	watchCmd.Flags().BoolVar(&watchOptions.clear, "clear", true, "clear the screen before each run")
+	jk.AddCommand(watchCmd)
+}
+
+func watchArgs(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("watch requires a command to run (generate, run or validate)")
+	}
+	if !watchCommands[args[0]] {
+		return fmt.Errorf("watch: cannot watch %q (expected generate, run or validate)", args[0])
+	}
+	return nil
+}
+
+func watch(cmd *cobra.Command, args []string) {
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Each run writes its dependencies to this file
+	f, err := ioutil.TempFile("", "jk-watch-*.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	f.Close()
+	recordFile := f.Name()
+	defer os.Remove(recordFile)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer watcher.Close()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	deps := &watchedFiles{watcher: watcher, dirs: map[string]bool{}}
+	for {
+		if watchOptions.clear {
+			fmt.Print("\033[H\033[2J")
+		}
+
+		start := time.Now()
+		status := "succeeded"
+		if err := runWatched(exe, args, recordFile); err != nil {
+			status = fmt.Sprintf("failed (%v)", err)
+		}
+		elapsed := time.Since(start)
+
+		// If the run failed before its dependencies were written,
+		// keep watching what was being watched; failing that, watch
+		// the files named in the arguments.
+		if files, err := readRecordedFiles(recordFile); err == nil {
+			deps.set(files)
+		} else if deps.files == nil {
+			deps.set(existingFiles(args[1:]))
+		}
+		fmt.Fprintf(os.Stderr, "[watch] %s %s in %s; watching %d files\n", args[0], status, elapsed.Round(time.Millisecond), len(deps.files))
+
+		if !deps.wait(watchOptions.debounce, interrupt) {
+			return
+		}
+	}
+}
+
+// runWatched runs the jk command given in a new process (and so, a
+// fresh VM), recording its dependencies to the file given.
+func runWatched(exe string, args []string, recordFile string) error {
+	os.Remove(recordFile)
+	cmdArgs := append([]string{args[0], "--record-dependencies", recordFile}, args[1:]...)
+	cmd := exec.Command(exe, cmdArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// readRecordedFiles returns the files used by a run, from the
+// recording it wrote.
+func readRecordedFiles(recordFile string) ([]string, error) {
+	data, err := ioutil.ReadFile(recordFile)
+	if err != nil {
+		return nil, err
+	}
+	var recorder record.Recorder
+	if err := json.Unmarshal(data, &recorder); err != nil {
+		return nil, err
+	}
+	inputs, _ := recorder.Files()
+	return inputs, nil
+}
+
+func existingFiles(args []string) []string {
+	var files []string
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil && !info.IsDir() {
+			if abs, err := filepath.Abs(arg); err == nil {
+				files = append(files, abs)
+			}
+		}
+	}
+	return files
+}
+
+// watchedFiles keeps track of the files that a run depends on, and
+// the directories watched to find out when they change. Directories
+// are watched rather than files, since editors often replace a file
+// rather than writing to it.
+type watchedFiles struct {
+	watcher *fsnotify.Watcher
+	files   map[string]bool
+	// directories that are themselves dependencies (e.g., listed
+	// with std.dir), so a change to any entry counts
+	listed map[string]bool
+	dirs   map[string]bool
+}
+
+func (w *watchedFiles) set(paths []string) {
+	w.files, w.listed = map[string]bool{}, map[string]bool{}
+	dirs := map[string]bool{}
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		w.files[p] = true
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			w.listed[p] = true
+			dirs[p] = true
+		}
+		dirs[filepath.Dir(p)] = true
+	}
+
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			w.watcher.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+	for dir := range dirs {
+		if w.dirs[dir] {
+			continue
+		}
+		// A directory that doesn't exist (yet) can't be watched;
+		// it's not an error to depend on a file that isn't there.
+		if err := w.watcher.Add(dir); err == nil {
+			w.dirs[dir] = true
+		}
+	}
+}
+
+func (w *watchedFiles) relevant(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Clean(event.Name)
+	return w.files[name] || w.listed[filepath.Dir(name)]
+}
+
+// wait blocks until a file depended on has changed, and no more
+// changes have been seen for the debounce period, then returns true;
+// or, if interrupted, returns false.
+func (w *watchedFiles) wait(debounce time.Duration, interrupt <-chan os.Signal) bool {
+	var quiet <-chan time.Time
+	for {
+		select {
+		case event := <-w.watcher.Events:
+			if w.relevant(event) {
+				quiet = time.After(debounce)
+			}
+		case err := <-w.watcher.Errors:
+			fmt.Fprintf(os.Stderr, "[watch] error: %v\n", err)
+		case <-quiet:
+			return true
+		case <-interrupt:
+			return false
+		}
+	}
+}